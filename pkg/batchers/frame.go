@@ -0,0 +1,161 @@
+package batchers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"google.golang.org/protobuf/encoding/protowire"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const (
+	// Field tags and length prefixes of the batch and its sequence number, which wrap the batched frames
+	batchOverhead = 16
+)
+
+type FrameWriter interface {
+	Write(ctx context.Context, frame *proto.FrameMessage) error
+}
+
+// FrameBatcher coalesces frames until either maximumFrames or maximumLength bytes have been collected or maximumDelay has passed since the first one
+type FrameBatcher struct {
+	maximumFrames int
+	maximumLength int
+	maximumDelay  time.Duration
+	frameWriter   FrameWriter
+	framePool     *pools.FramePool
+	frames        []*proto.FrameMessage
+	batch         *proto.FrameMessage
+	length        int
+	ctx           context.Context
+	timer         *time.Timer
+	err           error
+	lock          sync.Mutex
+}
+
+// NewFrameBatcher creates a batcher which returns frames to framePool once they have been written; maximumLength is the
+// length which marshalled batches shouldn't exceed, which depends on the transport
+func NewFrameBatcher(maximumFrames int, maximumLength int, maximumDelay time.Duration, frameWriter FrameWriter, framePool *pools.FramePool) *FrameBatcher {
+	batcher := &FrameBatcher{
+		maximumFrames: maximumFrames,
+		maximumLength: maximumLength,
+		maximumDelay:  maximumDelay,
+		frameWriter:   frameWriter,
+		framePool:     framePool,
+		batch:         &proto.FrameMessage{Batch: &proto.BatchMessage{}},
+	}
+
+	// The timer is reused for every batch instead of allocating a new one
+	batcher.timer = time.AfterFunc(maximumDelay, func() {
+		batcher.lock.Lock()
+		defer batcher.lock.Unlock()
+
+		batcher.err = batcher.flush(batcher.ctx)
+	})
+	batcher.timer.Stop()
+
+	return batcher
+}
+
+// Write takes ownership of the frame; batches which the timer flushes are written with the ctx of their first frame
+func (b *FrameBatcher) Write(ctx context.Context, frame *proto.FrameMessage) error {
+	if b.maximumFrames <= 1 {
+		defer b.framePool.PutMessage(frame)
+
+		return b.frameWriter.Write(ctx, frame)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Errors of flushes triggered by the timer are reported on the next write
+	if err := b.err; err != nil {
+		b.err = nil
+
+		return err
+	}
+
+	length := Length(frame)
+	if b.length+length > b.maximumLength-batchOverhead {
+		if err := b.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	b.frames = append(b.frames, frame)
+	b.length += length
+
+	if len(b.frames) >= b.maximumFrames {
+		return b.flush(ctx)
+	}
+
+	if len(b.frames) == 1 {
+		b.ctx = ctx
+		b.timer.Reset(b.maximumDelay)
+	}
+
+	return nil
+}
+
+func (b *FrameBatcher) Flush(ctx context.Context) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.flush(ctx)
+}
+
+func (b *FrameBatcher) flush(ctx context.Context) error {
+	b.timer.Stop()
+
+	if len(b.frames) == 0 {
+		return nil
+	}
+
+	frame := b.frames[0]
+	if len(b.frames) > 1 {
+		b.batch.Batch.Frames = b.frames
+		frame = b.batch
+	}
+
+	err := b.frameWriter.Write(ctx, frame)
+
+	// Frames are marshalled synchronously, so they can be reused as soon as they have been written
+	for i, frame := range b.frames {
+		b.framePool.PutMessage(frame)
+
+		b.frames[i] = nil
+	}
+
+	b.batch.Batch.Frames = nil
+	b.frames = b.frames[:0]
+	b.length = 0
+	b.ctx = nil
+
+	return err
+}
+
+// Length returns the length of a marshalled frame within a batch, including its field tag and length prefix
+func Length(frame *proto.FrameMessage) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(protobuf.Size(frame))
+}
+
+// Fits returns whether frames of length bytes in total fit into a batch of at most maximumLength bytes
+func Fits(length int, maximumLength int) bool {
+	return length <= maximumLength-batchOverhead
+}
+
+// NewBatch wraps frames into a batch, unless there is only one or none of them
+func NewBatch(frames []*proto.FrameMessage) *proto.FrameMessage {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	if len(frames) == 1 {
+		return frames[0]
+	}
+
+	return &proto.FrameMessage{Batch: &proto.BatchMessage{Frames: frames}}
+}
@@ -0,0 +1,141 @@
+package batchers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/transports"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// recordingWriter records the marshalled length of every written message, as the batcher reuses them afterwards
+type recordingWriter struct {
+	lengths []int
+	frames  int
+	lock    sync.Mutex
+}
+
+func (w *recordingWriter) Write(ctx context.Context, frame *proto.FrameMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// Streams add the largest possible sequence number before sending
+	sequenced := protobuf.Clone(frame).(*proto.FrameMessage)
+	sequenced.Sequence = math.MaxUint64
+
+	w.lengths = append(w.lengths, protobuf.Size(sequenced))
+
+	if frame.Batch != nil {
+		w.frames += len(frame.Batch.Frames)
+	} else {
+		w.frames++
+	}
+
+	return nil
+}
+
+func newFrame(framePool *pools.FramePool, contentLength int) *proto.FrameMessage {
+	frame := framePool.GetMessage()
+	frame.Content = make([]byte, contentLength)
+	frame.Sender = make([]byte, 32)
+	frame.Recipient = make([]byte, 32)
+
+	return frame
+}
+
+func TestFrameBatcherKeepsBatchesWithinDatagrams(t *testing.T) {
+	maximumLength := transports.NewDTLSTransport("", nil).MaximumBatchLength()
+
+	writer := &recordingWriter{}
+	framePool := pools.NewFramePool(0)
+	batcher := NewFrameBatcher(32, maximumLength, time.Hour, writer, framePool)
+
+	for i := 0; i < 100; i++ {
+		if err := batcher.Write(context.Background(), newFrame(framePool, 100+i*7%400)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := batcher.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if writer.frames != 100 {
+		t.Fatalf("expected 100 frames to be written, got %v", writer.frames)
+	}
+
+	if len(writer.lengths) >= 100 {
+		t.Fatalf("expected frames to be batched, got %v messages", len(writer.lengths))
+	}
+
+	for i, length := range writer.lengths {
+		if length > maximumLength {
+			t.Fatalf("expected message %v to be at most %v bytes long, got %v", i, maximumLength, length)
+		}
+	}
+}
+
+func TestFrameBatcherSendsLongFramesAlone(t *testing.T) {
+	maximumLength := transports.NewDTLSTransport("", nil).MaximumBatchLength()
+
+	writer := &recordingWriter{}
+	framePool := pools.NewFramePool(0)
+	batcher := NewFrameBatcher(32, maximumLength, time.Hour, writer, framePool)
+
+	for _, contentLength := range []int{100, maximumLength * 2, 100} {
+		if err := batcher.Write(context.Background(), newFrame(framePool, contentLength)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := batcher.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(writer.lengths) != 3 || writer.frames != 3 {
+		t.Fatalf("expected 3 messages with 3 frames, got %v messages with %v frames", len(writer.lengths), writer.frames)
+	}
+}
+
+// marshallingWriter marshals frames like streams do and discards them
+type marshallingWriter struct {
+	buffer []byte
+}
+
+func (w *marshallingWriter) Write(ctx context.Context, frame *proto.FrameMessage) (err error) {
+	w.buffer, err = protobuf.MarshalOptions{}.MarshalAppend(w.buffer[:0], frame)
+
+	return err
+}
+
+func BenchmarkFrameBatcherWrite(b *testing.B) {
+	for _, maximumFrames := range []int{1, 32} {
+		b.Run(fmt.Sprintf("%v frames", maximumFrames), func(b *testing.B) {
+			framePool := pools.NewFramePool(2048)
+			batcher := NewFrameBatcher(maximumFrames, transports.NewMemoryTransport().MaximumBatchLength(), time.Hour, &marshallingWriter{}, framePool)
+
+			b.ReportAllocs()
+			b.SetBytes(1514)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				frame := framePool.GetMessage()
+				frame.Content = framePool.GetBuffer()[:1514]
+
+				if err := batcher.Write(context.Background(), frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if err := batcher.Flush(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -2,59 +2,255 @@ package clients
 
 import (
 	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pojntfx/gloeth/pkg/lifecycles"
 	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/pojntfx/gloeth/pkg/transports"
+	"github.com/pojntfx/gloeth/pkg/validators"
+)
+
+const (
+	handshakeTimeout = time.Second * 10
+)
+
+var (
+	ErrHandshakeFailed = errors.New("handshake failed")
 )
 
 type FrameClient struct {
-	remoteAddress     string
-	remoteCertificate string
-	channel           proto.FrameService_TransceiveFramesClient
+	transport       transports.Transport
+	preSharedKey    string
+	publicKey       []byte
+	peerID          []byte
+	compressions    []proto.Compression
+	offload         bool
+	payloadType     proto.PayloadType
+	compression     int32
+	offloadEnabled  int32
+	stream          transports.Stream
+	sequence        uint64
+	replayValidator *validators.ReplayValidator
+	droppedReplays  uint64
+	batch           []*proto.FrameMessage
+	streamLock      sync.Mutex
+	lifecycle       *lifecycles.Lifecycle
 }
 
-func NewFrameClient(remoteAddress string, remoteCertificate string) *FrameClient {
-	return &FrameClient{remoteAddress, remoteCertificate, nil}
+// NewFrameClient creates a client; clients which share a peerID are treated as streams of the same peer by the hub,
+// compressions are offered to the hub in order of preference, offload enables receiving frames with offloads
+// and payloadType tells the hub whether the peer sends and receives Ethernet frames or IP packets
+func NewFrameClient(transport transports.Transport, preSharedKey string, publicKey []byte, peerID []byte, compressions []proto.Compression, offload bool, payloadType proto.PayloadType) *FrameClient {
+	return &FrameClient{
+		transport:    transport,
+		preSharedKey: preSharedKey,
+		publicKey:    publicKey,
+		peerID:       peerID,
+		compressions: compressions,
+		offload:      offload,
+		payloadType:  payloadType,
+		lifecycle:    lifecycles.NewLifecycle(),
+	}
 }
 
+// Open returns transports.ErrUnauthenticated or transports.ErrPermissionDenied if the hub rejected the client
 func (c *FrameClient) Open() error {
-	creds, err := credentials.NewClientTLSFromFile(c.remoteCertificate, "")
+	stream, err := c.transport.Dial()
 	if err != nil {
 		return err
 	}
 
-	connection, err := grpc.Dial(c.remoteAddress, grpc.WithTransportCredentials(creds))
+	welcome, err := c.handshake(stream)
 	if err != nil {
+		_ = stream.Close()
+
 		return err
 	}
 
-	client := proto.NewFrameServiceClient(connection)
+	c.streamLock.Lock()
 
-	channel, err := client.TransceiveFrames(context.Background())
-	if err != nil {
-		return err
+	select {
+	case <-c.lifecycle.Closed():
+		c.streamLock.Unlock()
+
+		_ = stream.Close()
+
+		return os.ErrClosed
+	default:
+	}
+
+	previousStream := c.stream
+
+	// Sequence numbers are scoped to a stream
+	atomic.StoreUint64(&c.sequence, 0)
+	c.replayValidator = validators.NewReplayValidator()
+	c.batch = nil
+	atomic.StoreInt32(&c.compression, int32(welcome.Compression))
+	if welcome.Offload {
+		atomic.StoreInt32(&c.offloadEnabled, 1)
+	} else {
+		atomic.StoreInt32(&c.offloadEnabled, 0)
+	}
+	c.stream = stream
+
+	c.streamLock.Unlock()
+
+	if previousStream != nil {
+		_ = previousStream.Close()
 	}
 
-	c.channel = channel
+	c.lifecycle.Open()
 
 	return nil
 }
 
-func (c *FrameClient) Write(frame *proto.FrameMessage) error {
-	c.waitTillOpen()
+// Write waits until the first stream has been opened or ctx is done
+func (c *FrameClient) Write(ctx context.Context, frame *proto.FrameMessage) error {
+	if err := c.WaitTillOpen(ctx); err != nil {
+		return err
+	}
+
+	c.streamLock.Lock()
+	stream := c.stream
+	c.streamLock.Unlock()
+
+	frame.Sequence = atomic.AddUint64(&c.sequence, 1)
 
-	return c.channel.Send(frame)
+	return stream.Send(frame)
 }
 
-func (c *FrameClient) Read() (*proto.FrameMessage, error) {
-	c.waitTillOpen()
+// Read waits until the first stream has been opened or ctx is done
+func (c *FrameClient) Read(ctx context.Context) (*proto.FrameMessage, error) {
+	if err := c.WaitTillOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		c.streamLock.Lock()
+
+		if len(c.batch) > 0 {
+			frame := c.batch[0]
+			c.batch = c.batch[1:]
+
+			c.streamLock.Unlock()
+
+			return frame, nil
+		}
+
+		stream, replayValidator := c.stream, c.replayValidator
+
+		c.streamLock.Unlock()
+
+		frame, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		if valid := replayValidator.Validate(frame.Sequence); !valid {
+			atomic.AddUint64(&c.droppedReplays, 1)
+
+			continue
+		}
+
+		if frame.Batch != nil {
+			// The batch belongs to a stream which may have been replaced in the meantime
+			c.streamLock.Lock()
+			if stream == c.stream {
+				c.batch = frame.Batch.Frames
+			}
+			c.streamLock.Unlock()
 
-	return c.channel.Recv()
+			continue
+		}
+
+		return frame, nil
+	}
+}
+
+// Close closes the current stream, which unblocks pending reads; the client can't be opened again afterwards
+func (c *FrameClient) Close() error {
+	if !c.lifecycle.Close() {
+		return nil
+	}
+
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+
+	if c.stream == nil {
+		return nil
+	}
+
+	return c.stream.Close()
+}
+
+// WaitTillOpen blocks until the first stream has been opened; it returns os.ErrClosed if the client has been closed
+func (c *FrameClient) WaitTillOpen(ctx context.Context) error {
+	return c.lifecycle.Wait(ctx)
+}
+
+func (c *FrameClient) DroppedReplays() uint64 {
+	return atomic.LoadUint64(&c.droppedReplays)
+}
+
+// Compression returns the compression which the hub chose for the current stream
+func (c *FrameClient) Compression() proto.Compression {
+	return proto.Compression(atomic.LoadInt32(&c.compression))
 }
 
-func (s *FrameClient) waitTillOpen() {
-	for s.channel == nil {
+// Offload returns whether the hub accepts frames with offloads on the current stream
+func (c *FrameClient) Offload() bool {
+	return atomic.LoadInt32(&c.offloadEnabled) == 1
+}
+
+func (c *FrameClient) handshake(stream transports.Stream) (*proto.WelcomeMessage, error) {
+	// Hubs which don't complete the handshake in time are disconnected, i.e. if the welcome was lost
+	handshaken := make(chan struct{})
+	defer close(handshaken)
+
+	go func() {
+		timer := time.NewTimer(handshakeTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			_ = stream.Close()
+		case <-handshaken:
+		}
+	}()
 
+	if err := stream.Send(&proto.FrameMessage{Hello: &proto.HelloMessage{PreSharedKey: c.preSharedKey, PublicKey: c.publicKey, Compressions: c.compressions, PeerID: c.peerID, Offload: c.offload, PayloadType: c.payloadType}}); err != nil {
+		return nil, err
 	}
+
+	welcome, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Frames which overtook the welcome on datagram transports are dropped
+	for welcome.Welcome == nil && (welcome.Content != nil || welcome.Batch != nil) {
+		if welcome, err = stream.Recv(); err != nil {
+			return nil, err
+		}
+	}
+
+	if welcome.Welcome == nil || (welcome.Welcome.Offload && !c.offload) {
+		return nil, ErrHandshakeFailed
+	}
+
+	if compression := welcome.Welcome.Compression; compression != proto.Compression_NONE {
+		for _, offered := range c.compressions {
+			if compression == offered {
+				return welcome.Welcome, nil
+			}
+		}
+
+		return nil, ErrHandshakeFailed
+	}
+
+	return welcome.Welcome, nil
 }
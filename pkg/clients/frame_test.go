@@ -0,0 +1,188 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/transports"
+)
+
+// newTestHub accepts streams on transport, completes their handshakes and returns them
+func newTestHub(t *testing.T, transport transports.Transport) <-chan transports.Stream {
+	t.Helper()
+
+	listener, err := transport.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	streams := make(chan transports.Stream, 10)
+	go func() {
+		for {
+			stream, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				hello, err := stream.Recv()
+				if err != nil || hello.Hello == nil {
+					_ = stream.Close()
+
+					return
+				}
+
+				if err := stream.Send(&proto.FrameMessage{Welcome: &proto.WelcomeMessage{}}); err != nil {
+					_ = stream.Close()
+
+					return
+				}
+
+				streams <- stream
+			}()
+		}
+	}()
+
+	return streams
+}
+
+// send sends the frame in the background, as memory streams block until the frame has been received
+func send(t *testing.T, stream transports.Stream, frame *proto.FrameMessage) {
+	t.Helper()
+
+	go func() {
+		if err := stream.Send(frame); err != nil {
+			t.Error(err)
+		}
+	}()
+}
+
+func TestFrameClientWaitsTillOpenOrContextDone(t *testing.T) {
+	frameClient := NewFrameClient(transports.NewMemoryTransport(), "", nil, nil, nil, false, proto.PayloadType_ETHERNET)
+	defer frameClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if _, err := frameClient.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if err := frameClient.Write(ctx, &proto.FrameMessage{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestFrameClientReconnects(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+	streams := newTestHub(t, transport)
+
+	frameClient := NewFrameClient(transport, "", nil, nil, nil, false, proto.PayloadType_ETHERNET)
+	defer frameClient.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := frameClient.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		stream := <-streams
+
+		// Sequence numbers start again on every stream
+		send(t, stream, &proto.FrameMessage{Content: []byte{byte(i)}, Sequence: 1})
+
+		frame, err := frameClient.Read(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(frame.Content, []byte{byte(i)}) {
+			t.Fatalf("expected frame %v, got %v", i, frame.Content)
+		}
+
+		received := make(chan *proto.FrameMessage, 1)
+		go func() {
+			frame, err := stream.Recv()
+			if err != nil {
+				t.Error(err)
+			}
+
+			received <- frame
+		}()
+
+		if err := frameClient.Write(context.Background(), &proto.FrameMessage{Content: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if frame = <-received; frame == nil {
+			t.FailNow()
+		}
+
+		if frame.Sequence != 1 {
+			t.Fatalf("expected sequence 1 on stream %v, got %v", i, frame.Sequence)
+		}
+
+		// The hub disconnects the client
+		if err := stream.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := frameClient.Read(context.Background()); err == nil {
+			t.Fatal("expected reads from a closed stream to fail")
+		}
+	}
+}
+
+func TestFrameClientCloseUnblocksReads(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+	streams := newTestHub(t, transport)
+
+	frameClient := NewFrameClient(transport, "", nil, nil, nil, false, proto.PayloadType_ETHERNET)
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := frameClient.Read(context.Background())
+
+		errs <- err
+	}()
+
+	if err := frameClient.Open(); err != nil {
+		t.Fatal(err)
+	}
+	<-streams
+
+	go func() {
+		_, err := frameClient.Read(context.Background())
+
+		errs <- err
+	}()
+
+	if err := frameClient.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Fatal("expected Close to unblock reads")
+		}
+	}
+
+	if err := frameClient.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := frameClient.Open(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+
+	if _, err := frameClient.Read(context.Background()); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+}
@@ -0,0 +1,157 @@
+package converters
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+const (
+	ethernetHeaderLength      = 14
+	ipHeaderLength            = 40
+	minimumCompressibleLength = 128
+	maximumDecompressedLength = 65535
+)
+
+var (
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+	ErrInvalidCompressedFrame = errors.New("invalid compressed frame")
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maximumDecompressedLength))
+)
+
+// ParseCompressions parses a list of compression names such as "zstd" into their protocol values
+func ParseCompressions(names []string) ([]proto.Compression, error) {
+	compressions := []proto.Compression{}
+	for _, name := range names {
+		compression, ok := proto.Compression_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedCompression, name)
+		}
+
+		compressions = append(compressions, proto.Compression(compression))
+	}
+
+	return compressions, nil
+}
+
+// headerLength is the length of the header which is left uncompressed; for IP packets, it covers an IPv6 header or
+// an IPv4 header and the ports, so that the hub can still route packets and hash their flows
+func headerLength(payloadType proto.PayloadType) int {
+	if payloadType == proto.PayloadType_IP {
+		return ipHeaderLength
+	}
+
+	return ethernetHeaderLength
+}
+
+// compressContent appends the compressed content to dst, leaving the header uncompressed so that the hub can still switch frames by their
+// addresses; tiny frames and frames which don't shrink noticeably (i.e. TLS or media traffic) are returned as-is with no compression instead
+func compressContent(dst []byte, compression proto.Compression, payloadType proto.PayloadType, content []byte) ([]byte, proto.Compression, error) {
+	header := headerLength(payloadType)
+	if compression == proto.Compression_NONE || len(content) < header+minimumCompressibleLength {
+		return content, proto.Compression_NONE, nil
+	}
+
+	compressed, err := compress(append(dst, content[:header]...), compression, content[header:])
+	if err != nil {
+		return nil, proto.Compression_NONE, err
+	}
+
+	if uncompressedLength := len(content) - header; len(compressed)-header >= uncompressedLength-uncompressedLength/16 {
+		return content, proto.Compression_NONE, nil
+	}
+
+	return compressed, compression, nil
+}
+
+func decompressContent(compression proto.Compression, payloadType proto.PayloadType, content []byte) ([]byte, error) {
+	if compression == proto.Compression_NONE {
+		return content, nil
+	}
+
+	header := headerLength(payloadType)
+	if len(content) < header {
+		return nil, ErrInvalidCompressedFrame
+	}
+
+	payload, err := decompress(compression, content[header:])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(make([]byte, 0, header+len(payload)), content[:header]...), payload...), nil
+}
+
+func compress(dst []byte, compression proto.Compression, payload []byte) ([]byte, error) {
+	switch compression {
+	case proto.Compression_ZSTD:
+		return zstdEncoder.EncodeAll(payload, dst), nil
+	case proto.Compression_LZ4:
+		// LZ4 blocks don't store their uncompressed length, so it is prepended
+		dst = binary.AppendUvarint(dst, uint64(len(payload)))
+
+		offset := len(dst)
+		dst = slices.Grow(dst, lz4.CompressBlockBound(len(payload)))
+
+		n, err := lz4.CompressBlock(payload, dst[offset:offset+lz4.CompressBlockBound(len(payload))], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return dst[:offset+n], nil
+	case proto.Compression_SNAPPY:
+		offset := len(dst)
+		dst = slices.Grow(dst, snappy.MaxEncodedLen(len(payload)))
+
+		// Snappy only encodes into dst if it can hold the worst case
+		encoded := snappy.Encode(dst[offset:offset+snappy.MaxEncodedLen(len(payload))], payload)
+
+		return dst[:offset+len(encoded)], nil
+	default:
+		return nil, ErrUnsupportedCompression
+	}
+}
+
+func decompress(compression proto.Compression, payload []byte) ([]byte, error) {
+	switch compression {
+	case proto.Compression_ZSTD:
+		return zstdDecoder.DecodeAll(payload, nil)
+	case proto.Compression_LZ4:
+		length, prefixLength := binary.Uvarint(payload)
+		if prefixLength <= 0 || length > maximumDecompressedLength {
+			return nil, ErrInvalidCompressedFrame
+		}
+
+		decompressed := make([]byte, length)
+		n, err := lz4.UncompressBlock(payload[prefixLength:], decompressed)
+		if err != nil {
+			return nil, err
+		}
+
+		return decompressed[:n], nil
+	case proto.Compression_SNAPPY:
+		length, err := snappy.DecodedLen(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if length > maximumDecompressedLength {
+			return nil, ErrInvalidCompressedFrame
+		}
+
+		return snappy.Decode(nil, payload)
+	default:
+		return nil, ErrUnsupportedCompression
+	}
+}
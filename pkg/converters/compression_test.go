@@ -0,0 +1,118 @@
+package converters
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+var (
+	compressions = []proto.Compression{proto.Compression_ZSTD, proto.Compression_LZ4, proto.Compression_SNAPPY}
+	payloadTypes = []proto.PayloadType{proto.PayloadType_ETHERNET, proto.PayloadType_IP}
+)
+
+// newCompressibleContent returns a header followed by a repetitive payload, like that of plain text protocols
+func newCompressibleContent(payloadType proto.PayloadType) []byte {
+	content := make([]byte, headerLength(payloadType))
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	return append(content, bytes.Repeat([]byte("GET /index.html HTTP/1.1\r\nHost: localhost\r\n\r\n"), 30)...)
+}
+
+func TestCompressContentRoundTrips(t *testing.T) {
+	for _, compression := range compressions {
+		for _, payloadType := range payloadTypes {
+			t.Run(compression.String()+"/"+payloadType.String(), func(t *testing.T) {
+				content := newCompressibleContent(payloadType)
+
+				compressed, actualCompression, err := compressContent(nil, compression, payloadType, content)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if actualCompression != compression || len(compressed) >= len(content) {
+					t.Fatalf("expected the content to be compressed with %v, got %v bytes with %v", compression, len(compressed), actualCompression)
+				}
+
+				// The hub switches and routes frames by their uncompressed headers
+				if header := headerLength(payloadType); !bytes.Equal(compressed[:header], content[:header]) {
+					t.Fatalf("expected the header %x to be left uncompressed, got %x", content[:header], compressed[:header])
+				}
+
+				decompressed, err := decompressContent(actualCompression, payloadType, compressed)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(decompressed, content) {
+					t.Fatalf("expected content %x, got %x", content, decompressed)
+				}
+			})
+		}
+	}
+}
+
+func TestCompressContentLeavesSmallAndIncompressibleContentUncompressed(t *testing.T) {
+	incompressible := make([]byte, 1400)
+	if _, err := rand.Read(incompressible); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compression := range compressions {
+		for _, payloadType := range payloadTypes {
+			for _, content := range [][]byte{
+				newCompressibleContent(payloadType)[:headerLength(payloadType)+minimumCompressibleLength-1],
+				incompressible,
+			} {
+				compressed, actualCompression, err := compressContent(nil, compression, payloadType, content)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if actualCompression != proto.Compression_NONE || !bytes.Equal(compressed, content) {
+					t.Fatalf("expected %v bytes of content to be left uncompressed with %v, got %v bytes with %v", len(content), compression, len(compressed), actualCompression)
+				}
+			}
+		}
+	}
+}
+
+func TestDecompressContentRejectsInvalidContent(t *testing.T) {
+	header := make([]byte, ethernetHeaderLength)
+
+	compressed, _, err := compressContent(nil, proto.Compression_ZSTD, proto.PayloadType_ETHERNET, newCompressibleContent(proto.PayloadType_ETHERNET))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors of the compression libraries themselves are only expected to be non-nil
+	tests := []struct {
+		name        string
+		compression proto.Compression
+		content     []byte
+		err         error
+	}{
+		{"content shorter than the header", proto.Compression_ZSTD, header[:ethernetHeaderLength-1], ErrInvalidCompressedFrame},
+		{"truncated zstd frame", proto.Compression_ZSTD, compressed[:len(compressed)/2], nil},
+		{"truncated lz4 length prefix", proto.Compression_LZ4, append(append([]byte{}, header...), 0x80), ErrInvalidCompressedFrame},
+		{"oversized lz4 length prefix", proto.Compression_LZ4, binary.AppendUvarint(append([]byte{}, header...), maximumDecompressedLength+1), ErrInvalidCompressedFrame},
+		{"truncated snappy length prefix", proto.Compression_SNAPPY, append(append([]byte{}, header...), 0x80), nil},
+		{"oversized snappy length prefix", proto.Compression_SNAPPY, append(binary.AppendUvarint(append([]byte{}, header...), maximumDecompressedLength+1), 0), ErrInvalidCompressedFrame},
+		{"unsupported compression", proto.Compression(42), append(append([]byte{}, header...), 0), ErrUnsupportedCompression},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decompressContent(test.compression, proto.PayloadType_ETHERNET, test.content)
+			if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+				t.Fatalf("expected decompressing to fail with %v, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -1,18 +1,177 @@
 package converters
 
-import proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+import (
+	"errors"
+
+	"github.com/pojntfx/gloeth/pkg/encryptors"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+var (
+	ErrPayloadTypeMismatch = errors.New("payload type mismatch")
+)
 
 type FrameConverter struct {
+	endToEndEncryptor *encryptors.EndToEndEncryptor
+	framePool         *pools.FramePool
+	offload           bool
+	payloadType       proto.PayloadType
 }
 
-func NewFrameConverter() *FrameConverter {
-	return &FrameConverter{}
+// NewFrameConverter creates a converter; offload must be set if raw frames are prefixed with a virtio net header, as with TAP devices with offloads enabled,
+// and payloadType is the type of the raw frames, which are Ethernet frames for TAP devices and IP packets for TUN devices
+func NewFrameConverter(endToEndEncryptor *encryptors.EndToEndEncryptor, framePool *pools.FramePool, offload bool, payloadType proto.PayloadType) *FrameConverter {
+	return &FrameConverter{endToEndEncryptor, framePool, offload, payloadType}
+}
+
+// ToExternal appends the external frames for rawFrame to frames; it takes ownership of rawFrame, which must be
+// a buffer from the frame pool, and returns frames which can be returned to the pool once they have been sent.
+// Offloads are only kept if offload is set, otherwise super-frames are segmented in software.
+func (c *FrameConverter) ToExternal(frames []*proto.FrameMessage, rawFrame []byte, compression proto.Compression, offload bool) ([]*proto.FrameMessage, error) {
+	var offloadMessage *proto.OffloadMessage
+	if c.offload {
+		if len(rawFrame) < virtioNetHeaderLength {
+			return frames, ErrInvalidOffload
+		}
+
+		offloadMessage = parseVirtioNetHeader(rawFrame)
+
+		// Moving the frame instead of slicing off the header keeps the buffer's full capacity for the pool
+		rawFrame = rawFrame[:copy(rawFrame, rawFrame[virtioNetHeaderLength:])]
+	}
+
+	// Sealed frames are opaque to the hub, which therefore couldn't segment them for peers without offloads
+	if offloadMessage != nil && (!offload || c.endToEndEncryptor != nil) {
+		segmentedFrames, err := segment([][]byte{}, rawFrame, offloadMessage)
+		c.framePool.PutBuffer(rawFrame)
+		if err != nil {
+			return frames, err
+		}
+
+		for _, segmentedFrame := range segmentedFrames {
+			if frames, err = c.toExternal(frames, segmentedFrame, compression, nil); err != nil {
+				return frames, err
+			}
+		}
+
+		return frames, nil
+	}
+
+	return c.toExternal(frames, rawFrame, compression, offloadMessage)
+}
+
+// toExternal compresses before sealing, as ciphertext is incompressible
+func (c *FrameConverter) toExternal(frames []*proto.FrameMessage, rawFrame []byte, compression proto.Compression, offload *proto.OffloadMessage) ([]*proto.FrameMessage, error) {
+	content := rawFrame
+	if compression != proto.Compression_NONE {
+		buffer := c.framePool.GetBuffer()
+
+		compressed, actualCompression, err := compressContent(buffer[:0], compression, c.payloadType, rawFrame)
+		if err != nil {
+			c.framePool.PutBuffer(buffer)
+
+			return frames, err
+		}
+
+		if actualCompression == proto.Compression_NONE {
+			c.framePool.PutBuffer(buffer)
+		} else {
+			c.framePool.PutBuffer(rawFrame)
+
+			content = compressed
+		}
+
+		compression = actualCompression
+	}
+
+	if c.endToEndEncryptor != nil {
+		sealedFrames, err := c.endToEndEncryptor.Seal(content)
+		c.framePool.PutBuffer(content)
+		if err != nil {
+			return frames, err
+		}
+
+		for _, frame := range sealedFrames {
+			frame.Compression = compression
+			frame.PayloadType = c.payloadType
+		}
+
+		return append(frames, sealedFrames...), nil
+	}
+
+	frame := c.framePool.GetMessage()
+	frame.Content = content
+	frame.Compression = compression
+	frame.Offload = offload
+	frame.PayloadType = c.payloadType
+
+	return append(frames, frame), nil
 }
 
-func (c *FrameConverter) ToExternal(rawFrame []byte, preSharedKey string) (*proto.FrameMessage, error) {
-	return &proto.FrameMessage{Content: rawFrame, PreSharedKey: preSharedKey}, nil
+// ToInternal appends the raw frames for frame to rawFrames; there can be more than one if a super-frame has to be segmented
+func (c *FrameConverter) ToInternal(rawFrames [][]byte, frame *proto.FrameMessage) ([][]byte, error) {
+	// Ethernet frames can't be written to TUN devices and vice versa
+	if frame.PayloadType != c.payloadType {
+		return rawFrames, ErrPayloadTypeMismatch
+	}
+
+	content := frame.Content
+	if c.endToEndEncryptor != nil {
+		opened, err := c.endToEndEncryptor.Open(frame)
+		if err != nil {
+			return rawFrames, err
+		}
+
+		content = opened
+	}
+
+	rawFrame, err := decompressContent(frame.Compression, frame.PayloadType, content)
+	if err != nil {
+		return rawFrames, err
+	}
+
+	if c.offload {
+		return append(rawFrames, append(appendVirtioNetHeader(make([]byte, 0, virtioNetHeaderLength+len(rawFrame)), frame.Offload), rawFrame...)), nil
+	}
+
+	if frame.Offload != nil {
+		return segment(rawFrames, rawFrame, frame.Offload)
+	}
+
+	return append(rawFrames, rawFrame), nil
 }
 
-func (c *FrameConverter) ToInternal(frame *proto.FrameMessage) ([]byte, string, error) {
-	return frame.Content, frame.PreSharedKey, nil
+// Transcode appends a frame recompressed and, for streams without offloads, segmented to frames for a stream which negotiated
+// different options than the one it was received from; end-to-end encrypted frames are opaque to the hub and are passed through as-is
+func (c *FrameConverter) Transcode(frames []*proto.FrameMessage, frame *proto.FrameMessage, compression proto.Compression, offload bool) ([]*proto.FrameMessage, error) {
+	if len(frame.Recipient) > 0 || len(frame.Content) == 0 || (frame.Compression == compression && (frame.Offload == nil || offload)) {
+		return append(frames, frame), nil
+	}
+
+	rawFrame, err := decompressContent(frame.Compression, frame.PayloadType, frame.Content)
+	if err != nil {
+		return frames, err
+	}
+
+	rawFrames := [][]byte{rawFrame}
+	offloadMessage := frame.Offload
+	if offloadMessage != nil && !offload {
+		if rawFrames, err = segment([][]byte{}, rawFrame, offloadMessage); err != nil {
+			return frames, err
+		}
+
+		offloadMessage = nil
+	}
+
+	for _, rawFrame := range rawFrames {
+		content, actualCompression, err := compressContent(nil, compression, frame.PayloadType, rawFrame)
+		if err != nil {
+			return frames, err
+		}
+
+		frames = append(frames, &proto.FrameMessage{Content: content, Sender: frame.Sender, Compression: actualCompression, Offload: offloadMessage, PayloadType: frame.PayloadType})
+	}
+
+	return frames, nil
 }
@@ -0,0 +1,130 @@
+package converters
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+func TestToExternalDoesntRecycleFramesWhichAreStillSent(t *testing.T) {
+	framePool := pools.NewFramePool(2048)
+	frameConverter := NewFrameConverter(nil, framePool, true, proto.PayloadType_ETHERNET)
+
+	expectedFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 10)
+	for i := range expectedFrame {
+		expectedFrame[i] |= byte(i)
+	}
+
+	// The kernel only marked the checksum as valid, so there is nothing to segment
+	rawFrame := framePool.GetBuffer()
+	rawFrame = rawFrame[:copy(rawFrame, append([]byte{0x02, virtioNetHeaderGSONone, 0, 0, 0, 0, 0, 0, 0, 0}, expectedFrame...))]
+
+	frames, err := frameConverter.ToExternal([]*proto.FrameMessage{}, rawFrame, proto.Compression_NONE, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next frame which is read from the device reuses the recycled buffer
+	nextFrame := framePool.GetBuffer()
+	for i := range nextFrame {
+		nextFrame[i] = 0xff
+	}
+
+	if len(frames) != 1 || !bytes.Equal(frames[0].Content, expectedFrame) {
+		t.Fatalf("expected frame %x, got %x", expectedFrame, frames[0].Content)
+	}
+}
+
+func TestTranscodeRecompressesForSessionsWithOtherCompressions(t *testing.T) {
+	framePool := pools.NewFramePool(2048)
+	frameConverter := NewFrameConverter(nil, framePool, false, proto.PayloadType_ETHERNET)
+
+	content := newCompressibleContent(proto.PayloadType_ETHERNET)
+
+	rawFrame := framePool.GetBuffer()
+	frames, err := frameConverter.ToExternal([]*proto.FrameMessage{}, rawFrame[:copy(rawFrame, content)], proto.Compression_ZSTD, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 1 || frames[0].Compression != proto.Compression_ZSTD {
+		t.Fatalf("expected a frame compressed with %v, got %v", proto.Compression_ZSTD, frames)
+	}
+
+	for _, compression := range []proto.Compression{proto.Compression_NONE, proto.Compression_ZSTD, proto.Compression_LZ4, proto.Compression_SNAPPY} {
+		transcoded, err := frameConverter.Transcode([]*proto.FrameMessage{}, frames[0], compression, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(transcoded) != 1 || transcoded[0].Compression != compression {
+			t.Fatalf("expected a frame compressed with %v, got %v", compression, transcoded)
+		}
+
+		rawFrames, err := frameConverter.ToInternal([][]byte{}, transcoded[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rawFrames) != 1 || !bytes.Equal(rawFrames[0], content) {
+			t.Fatalf("expected frame %x with %v, got %x", content, compression, rawFrames)
+		}
+	}
+}
+
+func benchmarkFrameConverterToExternal(b *testing.B, frameConverter *FrameConverter, framePool *pools.FramePool, rawFrame []byte, compression proto.Compression) {
+	frames := []*proto.FrameMessage{}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(rawFrame)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		// ToExternal takes ownership of the buffer, as if it had been read from the device
+		buffer := framePool.GetBuffer()
+		buffer = buffer[:copy(buffer, rawFrame)]
+
+		var err error
+		frames, err = frameConverter.ToExternal(frames[:0], buffer, compression, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, frame := range frames {
+			framePool.PutMessage(frame)
+		}
+	}
+}
+
+func BenchmarkFrameConverterToExternal(b *testing.B) {
+	// A full-sized segment with a somewhat compressible payload
+	rawFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 1460)
+	for i := ethernetHeaderLength + ipv4HeaderLength + tcpHeaderLength; i < len(rawFrame); i++ {
+		rawFrame[i] = byte(i % 64)
+	}
+
+	for _, compression := range []proto.Compression{proto.Compression_NONE, proto.Compression_ZSTD, proto.Compression_LZ4, proto.Compression_SNAPPY} {
+		b.Run(compression.String(), func(b *testing.B) {
+			framePool := pools.NewFramePool(2048)
+
+			benchmarkFrameConverterToExternal(b, NewFrameConverter(nil, framePool, false, proto.PayloadType_ETHERNET), framePool, rawFrame, compression)
+		})
+	}
+
+	b.Run("SEGMENT", func(b *testing.B) {
+		superFrame := append(appendVirtioNetHeader([]byte{}, &proto.OffloadMessage{
+			Flags:          virtioNetHeaderFlagNeedsChecksum,
+			GSOType:        virtioNetHeaderGSOTCPv4,
+			HeaderLength:   ethernetHeaderLength + ipv4HeaderLength + tcpHeaderLength,
+			SegmentSize:    1448,
+			ChecksumStart:  ethernetHeaderLength + ipv4HeaderLength,
+			ChecksumOffset: 16,
+		}), newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 1448*8)...)
+
+		framePool := pools.NewFramePool(len(superFrame))
+
+		benchmarkFrameConverterToExternal(b, NewFrameConverter(nil, framePool, true, proto.PayloadType_ETHERNET), framePool, superFrame, proto.Compression_NONE)
+	})
+}
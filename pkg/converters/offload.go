@@ -0,0 +1,222 @@
+package converters
+
+import (
+	"encoding/binary"
+	"errors"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+const (
+	// struct virtio_net_hdr, which the kernel prepends to frames of TAP devices with IFF_VNET_HDR
+	virtioNetHeaderLength = 10
+
+	virtioNetHeaderFlagNeedsChecksum = 0x01
+
+	virtioNetHeaderGSONone  = 0x00
+	virtioNetHeaderGSOTCPv4 = 0x01
+	virtioNetHeaderGSOTCPv6 = 0x04
+	virtioNetHeaderGSOECN   = 0x80
+
+	etherTypeVLAN      = 0x8100
+	ipv4HeaderLength   = 20
+	ipv6HeaderLength   = 40
+	tcpHeaderLength    = 20
+	udpChecksumOffset  = 6
+	tcpFlagFIN         = 0x01
+	tcpFlagPSH         = 0x08
+	tcpFlagCWR         = 0x80
+	protocolTCP        = 6
+	pseudoHeaderLength = 40
+
+	// Smaller segments would let a peer make the hub send a segment for every few bytes of a super-frame
+	minimumSegmentSize = 64
+)
+
+var (
+	ErrUnsupportedOffload = errors.New("unsupported offload")
+	ErrInvalidOffload     = errors.New("invalid offload")
+)
+
+// parseVirtioNetHeader returns nil if the frame doesn't need any offloads
+func parseVirtioNetHeader(header []byte) *proto.OffloadMessage {
+	if header[0] == 0 && header[1] == virtioNetHeaderGSONone {
+		return nil
+	}
+
+	return &proto.OffloadMessage{
+		Flags:          uint32(header[0]),
+		GSOType:        uint32(header[1]),
+		HeaderLength:   uint32(binary.LittleEndian.Uint16(header[2:4])),
+		SegmentSize:    uint32(binary.LittleEndian.Uint16(header[4:6])),
+		ChecksumStart:  uint32(binary.LittleEndian.Uint16(header[6:8])),
+		ChecksumOffset: uint32(binary.LittleEndian.Uint16(header[8:10])),
+	}
+}
+
+func appendVirtioNetHeader(dst []byte, offload *proto.OffloadMessage) []byte {
+	if offload == nil {
+		return append(dst, make([]byte, virtioNetHeaderLength)...)
+	}
+
+	dst = append(dst, byte(offload.Flags), byte(offload.GSOType))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offload.HeaderLength))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offload.SegmentSize))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offload.ChecksumStart))
+
+	return binary.LittleEndian.AppendUint16(dst, uint16(offload.ChecksumOffset))
+}
+
+// segment does in software what the kernel would do for a device with offloads: it splits TCP super-frames into
+// segments and completes partial checksums. The frame itself is never modified, as it may be shared between sessions,
+// and never returned, so that it can be recycled, i.e. if the kernel only marked its checksum as valid.
+func segment(rawFrames [][]byte, rawFrame []byte, offload *proto.OffloadMessage) ([][]byte, error) {
+	gsoType := offload.GSOType &^ virtioNetHeaderGSOECN
+
+	if gsoType == virtioNetHeaderGSONone {
+		completed := append([]byte{}, rawFrame...)
+		if offload.Flags&virtioNetHeaderFlagNeedsChecksum == 0 {
+			return append(rawFrames, completed), nil
+		}
+
+		if err := completeChecksum(completed, int(offload.ChecksumStart), int(offload.ChecksumOffset)); err != nil {
+			return rawFrames, err
+		}
+
+		return append(rawFrames, completed), nil
+	}
+
+	if gsoType != virtioNetHeaderGSOTCPv4 && gsoType != virtioNetHeaderGSOTCPv6 {
+		return rawFrames, ErrUnsupportedOffload
+	}
+
+	networkOffset := ethernetHeaderLength
+	if len(rawFrame) >= ethernetHeaderLength && binary.BigEndian.Uint16(rawFrame[12:14]) == etherTypeVLAN {
+		networkOffset += 4
+	}
+
+	ipv4 := gsoType == virtioNetHeaderGSOTCPv4
+	networkHeaderLength := ipv6HeaderLength
+	if ipv4 {
+		networkHeaderLength = ipv4HeaderLength
+	}
+
+	transportOffset := int(offload.ChecksumStart)
+	segmentSize := int(offload.SegmentSize)
+	if segmentSize < minimumSegmentSize || transportOffset < networkOffset+networkHeaderLength || len(rawFrame) < transportOffset+tcpHeaderLength {
+		return rawFrames, ErrInvalidOffload
+	}
+
+	// The header lengths are read from the frame, which may have been sent by an untrusted peer
+	internetHeaderLength := int(rawFrame[networkOffset]&0x0f) * 4
+	if ipv4 && (internetHeaderLength < ipv4HeaderLength || networkOffset+internetHeaderLength > transportOffset) {
+		return rawFrames, ErrInvalidOffload
+	}
+
+	transmissionControlHeaderLength := int(rawFrame[transportOffset+12]>>4) * 4
+	headerLength := transportOffset + transmissionControlHeaderLength
+	if transmissionControlHeaderLength < tcpHeaderLength || len(rawFrame) < headerLength {
+		return rawFrames, ErrInvalidOffload
+	}
+
+	payload := rawFrame[headerLength:]
+	sequence := binary.BigEndian.Uint32(rawFrame[transportOffset+4 : transportOffset+8])
+	flags := rawFrame[transportOffset+13]
+	id := binary.BigEndian.Uint16(rawFrame[networkOffset+4 : networkOffset+6])
+
+	for i, offset := 0, 0; offset < len(payload); i, offset = i+1, offset+segmentSize {
+		end := offset + segmentSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+
+		segmentedFrame := make([]byte, headerLength+end-offset)
+		copy(segmentedFrame, rawFrame[:headerLength])
+		copy(segmentedFrame[headerLength:], payload[offset:end])
+
+		network := segmentedFrame[networkOffset:]
+		transport := segmentedFrame[transportOffset:]
+
+		// Only the first segment keeps CWR, and only the last one keeps FIN and PSH
+		segmentFlags := flags
+		if offset > 0 {
+			segmentFlags &^= tcpFlagCWR
+		}
+		if end < len(payload) {
+			segmentFlags &^= tcpFlagFIN | tcpFlagPSH
+		}
+
+		binary.BigEndian.PutUint32(transport[4:8], sequence+uint32(offset))
+		transport[13] = segmentFlags
+
+		if ipv4 {
+			binary.BigEndian.PutUint16(network[2:4], uint16(len(network)))
+			binary.BigEndian.PutUint16(network[4:6], id+uint16(i))
+			binary.BigEndian.PutUint16(network[10:12], 0)
+			binary.BigEndian.PutUint16(network[10:12], ^fold(sum(0, network[:internetHeaderLength])))
+		} else {
+			binary.BigEndian.PutUint16(network[4:6], uint16(len(network)-ipv6HeaderLength))
+		}
+
+		binary.BigEndian.PutUint16(transport[16:18], 0)
+		binary.BigEndian.PutUint16(transport[16:18], ^fold(sum(pseudoHeaderSum(network, ipv4, len(transport)), transport)))
+
+		rawFrames = append(rawFrames, segmentedFrame)
+	}
+
+	return rawFrames, nil
+}
+
+// completeChecksum finishes a partial checksum; the kernel has already stored the pseudo header's sum in the checksum field
+func completeChecksum(rawFrame []byte, checksumStart int, checksumOffset int) error {
+	if checksumStart+checksumOffset+2 > len(rawFrame) {
+		return ErrInvalidOffload
+	}
+
+	checksum := ^fold(sum(0, rawFrame[checksumStart:]))
+	if checksum == 0 && checksumOffset == udpChecksumOffset {
+		// A zero UDP checksum means that there is none
+		checksum = 0xffff
+	}
+
+	binary.BigEndian.PutUint16(rawFrame[checksumStart+checksumOffset:], checksum)
+
+	return nil
+}
+
+func pseudoHeaderSum(network []byte, ipv4 bool, transportLength int) uint32 {
+	pseudoHeader := make([]byte, 0, pseudoHeaderLength)
+	if ipv4 {
+		pseudoHeader = append(pseudoHeader, network[12:20]...)
+		pseudoHeader = append(pseudoHeader, 0, protocolTCP)
+		pseudoHeader = binary.BigEndian.AppendUint16(pseudoHeader, uint16(transportLength))
+	} else {
+		pseudoHeader = append(pseudoHeader, network[8:40]...)
+		pseudoHeader = binary.BigEndian.AppendUint32(pseudoHeader, uint32(transportLength))
+		pseudoHeader = append(pseudoHeader, 0, 0, 0, protocolTCP)
+	}
+
+	return sum(0, pseudoHeader)
+}
+
+// sum adds data to an Internet checksum (RFC 1071)
+func sum(initial uint32, data []byte) uint32 {
+	total := initial
+	for ; len(data) >= 2; data = data[2:] {
+		total += uint32(binary.BigEndian.Uint16(data))
+	}
+
+	if len(data) == 1 {
+		total += uint32(data[0]) << 8
+	}
+
+	return total
+}
+
+func fold(total uint32) uint16 {
+	for total > 0xffff {
+		total = (total >> 16) + (total & 0xffff)
+	}
+
+	return uint16(total)
+}
@@ -0,0 +1,135 @@
+package converters
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+// newTCPv4SuperFrame returns an Ethernet frame with an IPv4 header of internetHeaderLength bytes, a TCP header with the given data offset and payloadLength bytes of payload
+func newTCPv4SuperFrame(internetHeaderLength int, dataOffset int, payloadLength int) []byte {
+	rawFrame := make([]byte, ethernetHeaderLength+internetHeaderLength+tcpHeaderLength+payloadLength)
+	binary.BigEndian.PutUint16(rawFrame[12:14], 0x0800)
+
+	network := rawFrame[ethernetHeaderLength:]
+	network[0] = 0x40 | byte(internetHeaderLength/4)
+	network[9] = protocolTCP
+
+	transport := network[internetHeaderLength:]
+	transport[12] = byte(dataOffset/4) << 4
+
+	return rawFrame
+}
+
+func TestSegmentRejectsMalformedOffloads(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawFrame []byte
+		offload  *proto.OffloadMessage
+	}{
+		{
+			"internet header length beyond the frame",
+			func() []byte {
+				rawFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 1)
+				rawFrame[ethernetHeaderLength] = 0x4f
+
+				return rawFrame
+			}(),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"internet header length beyond the transport header",
+			func() []byte {
+				rawFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 100)
+				rawFrame[ethernetHeaderLength] = 0x46
+
+				return rawFrame
+			}(),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"internet header length too short",
+			func() []byte {
+				rawFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 100)
+				rawFrame[ethernetHeaderLength] = 0x41
+
+				return rawFrame
+			}(),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"transmission control header length too short",
+			newTCPv4SuperFrame(ipv4HeaderLength, 0, 100),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"transmission control header length beyond the frame",
+			newTCPv4SuperFrame(ipv4HeaderLength, 60, 10),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"checksum start beyond the frame",
+			newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 10),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 65535, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"checksum start within the IPv6 header",
+			newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 100),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv6, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"zero segment size",
+			newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 100),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34},
+		},
+		{
+			"segment size below the minimum",
+			newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 65535-ethernetHeaderLength-ipv4HeaderLength-tcpHeaderLength),
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: 1},
+		},
+		{
+			"empty frame",
+			[]byte{},
+			&proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize},
+		},
+		{
+			"checksum offset beyond the frame",
+			newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, 0),
+			&proto.OffloadMessage{Flags: virtioNetHeaderFlagNeedsChecksum, ChecksumStart: 34, ChecksumOffset: 65535},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := segment([][]byte{}, test.rawFrame, test.offload); !errors.Is(err, ErrInvalidOffload) {
+				t.Fatalf("expected %v, got %v", ErrInvalidOffload, err)
+			}
+		})
+	}
+}
+
+func TestSegmentSplitsSuperFrames(t *testing.T) {
+	rawFrame := newTCPv4SuperFrame(ipv4HeaderLength, tcpHeaderLength, minimumSegmentSize*2+5)
+
+	segmentedFrames, err := segment([][]byte{}, rawFrame, &proto.OffloadMessage{GSOType: virtioNetHeaderGSOTCPv4, ChecksumStart: 34, SegmentSize: minimumSegmentSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segmentedFrames) != 3 {
+		t.Fatalf("expected 3 segments, got %v", len(segmentedFrames))
+	}
+
+	for i, expectedLength := range []int{minimumSegmentSize, minimumSegmentSize, 5} {
+		network := segmentedFrames[i][ethernetHeaderLength:]
+		if length := int(binary.BigEndian.Uint16(network[2:4])); length != ipv4HeaderLength+tcpHeaderLength+expectedLength {
+			t.Fatalf("expected segment %v to have a total length of %v, got %v", i, ipv4HeaderLength+tcpHeaderLength+expectedLength, length)
+		}
+
+		if checksum := fold(sum(0, network[:ipv4HeaderLength])); checksum != 0xffff {
+			t.Fatalf("expected segment %v to have a valid IPv4 header checksum, got %x", i, checksum)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/pojntfx/gloeth/pkg/lifecycles"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	tunDevicePath = "/dev/net/tun"
+)
+
+// Device is a network device with one or more queues, each of which can be read from and written to concurrently
+type Device interface {
+	Open() error
+	Queues() int
+	// Read and Write wait until the device has been opened or ctx is done
+	Read(ctx context.Context, queue int) ([]byte, error)
+	Write(ctx context.Context, queue int, rawFrame []byte) error
+	Close() error
+}
+
+// queueDevice implements the queues which TAP and TUN devices share; they only differ in their flags
+type queueDevice struct {
+	deviceName              string
+	maximumTransmissionUnit int
+	queues                  int
+	flags                   uint16
+	offload                 bool
+	addresses               []*netlink.Addr
+	routes                  []*netlink.Route
+	namespace               string
+	framePool               *pools.FramePool
+	handle                  *netlink.Handle
+	devices                 []*os.File
+	lifecycle               *lifecycles.Lifecycle
+	lock                    sync.Mutex
+}
+
+func newQueueDevice(deviceName string, maximumTransmissionUnit int, queues int, flags uint16, offload bool, addresses []*netlink.Addr, routes []*netlink.Route, namespace string, framePool *pools.FramePool) *queueDevice {
+	flags |= unix.IFF_NO_PI
+	if queues > 1 {
+		flags |= unix.IFF_MULTI_QUEUE
+	}
+	if offload {
+		flags |= unix.IFF_VNET_HDR
+	}
+
+	return &queueDevice{deviceName, maximumTransmissionUnit, queues, flags, offload, addresses, routes, namespace, framePool, nil, nil, lifecycles.NewLifecycle(), sync.Mutex{}}
+}
+
+func (d *queueDevice) Open() error {
+	return d.open(nil)
+}
+
+// open opens the queues and configures the link; configure, if set, is called before the link is brought up
+func (d *queueDevice) open(configure func(link netlink.Link) error) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	select {
+	case <-d.lifecycle.Closed():
+		return os.ErrClosed
+	default:
+	}
+
+	handle, err := openHandle(d.namespace)
+	if err != nil {
+		return err
+	}
+
+	devices := []*os.File{}
+	closeAll := func() {
+		for _, device := range devices {
+			_ = device.Close()
+		}
+
+		handle.Delete()
+	}
+
+	// The device is created in the namespace of the thread which opens it, but its queues can be used from any namespace
+	if err := inNamespace(d.namespace, func() error {
+		for i := 0; i < d.queues; i++ {
+			// Opening the same multiqueue device again attaches another queue to it
+			device, err := d.openQueue()
+			if err != nil {
+				return err
+			}
+
+			devices = append(devices, device)
+		}
+
+		return nil
+	}); err != nil {
+		closeAll()
+
+		return err
+	}
+
+	d.handle = handle
+
+	if err := d.configureLink(configure); err != nil {
+		closeAll()
+
+		d.handle = nil
+
+		return err
+	}
+
+	d.devices = devices
+	d.lifecycle.Open()
+
+	return nil
+}
+
+// Close removes the addresses and routes and closes the queues, which removes the device
+func (d *queueDevice) Close() error {
+	return d.close(nil)
+}
+
+// close closes the device; deconfigure, if set, is called before the addresses and routes are removed
+func (d *queueDevice) close(deconfigure func(link netlink.Link) error) error {
+	if !d.lifecycle.Close() {
+		return nil
+	}
+
+	// Waits for a concurrent Open to finish, so that its queues are closed as well
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.handle == nil {
+		return nil
+	}
+
+	errs := []error{d.deconfigureLink(deconfigure)}
+	for _, device := range d.devices {
+		errs = append(errs, device.Close())
+	}
+
+	d.handle.Delete()
+
+	return errors.Join(errs...)
+}
+
+func (d *queueDevice) Queues() int {
+	return d.queues
+}
+
+func (d *queueDevice) Write(ctx context.Context, queue int, rawFrame []byte) error {
+	if err := d.WaitTillOpen(ctx); err != nil {
+		return err
+	}
+
+	_, err := d.devices[queue].Write(rawFrame)
+
+	return err
+}
+
+// Read returns a buffer from the frame pool, which the caller should return to it once the frame has been sent
+func (d *queueDevice) Read(ctx context.Context, queue int) ([]byte, error) {
+	if err := d.WaitTillOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	readFrame := d.framePool.GetBuffer()
+
+	n, err := d.devices[queue].Read(readFrame)
+	if err != nil {
+		d.framePool.PutBuffer(readFrame)
+
+		return nil, err
+	}
+
+	return readFrame[:n], nil
+}
+
+func (d *queueDevice) openQueue() (*os.File, error) {
+	fd, err := unix.Open(tunDevicePath, unix.O_RDWR|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := unix.NewIfreq(d.deviceName)
+	if err != nil {
+		_ = unix.Close(fd)
+
+		return nil, err
+	}
+
+	request.SetUint16(d.flags)
+
+	if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, request); err != nil {
+		_ = unix.Close(fd)
+
+		return nil, err
+	}
+
+	if d.offload {
+		if err := unix.IoctlSetInt(fd, unix.TUNSETOFFLOAD, unix.TUN_F_CSUM|unix.TUN_F_TSO4|unix.TUN_F_TSO6|unix.TUN_F_TSO_ECN); err != nil {
+			_ = unix.Close(fd)
+
+			return nil, err
+		}
+	}
+
+	// Non-blocking file descriptors are handled by the runtime's poller instead of blocking a thread each
+	return os.NewFile(uintptr(fd), tunDevicePath), nil
+}
+
+// WaitTillOpen blocks until the device has been opened; it returns os.ErrClosed if the device has been closed
+func (d *queueDevice) WaitTillOpen(ctx context.Context) error {
+	return d.lifecycle.Wait(ctx)
+}
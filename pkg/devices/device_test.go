@@ -0,0 +1,121 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// newTestTUNDevice returns a TUN device with a unique name; creating it requires CAP_NET_ADMIN
+func newTestTUNDevice(t *testing.T, queues int) *TUNDevice {
+	t.Helper()
+
+	if err := unix.Access(tunDevicePath, unix.R_OK|unix.W_OK); err != nil || os.Geteuid() != 0 {
+		t.Skip("creating TUN devices requires root and", tunDevicePath)
+	}
+
+	return NewTUNDevice(fmt.Sprintf("gltest%v", time.Now().UnixNano()%100000), 1500, queues, nil, nil, "", pools.NewFramePool(1500))
+}
+
+func TestQueueDeviceWaitsTillOpenOrContextDone(t *testing.T) {
+	device := newTestTUNDevice(t, 1)
+	defer device.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if _, err := device.Read(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if err := device.Write(ctx, 0, []byte{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestQueueDeviceCloseUnblocksWaiters(t *testing.T) {
+	device := newTestTUNDevice(t, 1)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := device.Read(context.Background(), 0)
+
+		errs <- err
+	}()
+
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-errs; !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := device.Open(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestQueueDeviceCloseRemovesDevice(t *testing.T) {
+	device := newTestTUNDevice(t, 2)
+
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := netlink.LinkByName(device.deviceName); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := netlink.LinkByName(device.deviceName); err == nil {
+		t.Fatalf("expected device %v to be removed", device.deviceName)
+	}
+}
+
+func TestQueueDeviceOpenAndCloseConcurrently(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		device := newTestTUNDevice(t, 2)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			if err := device.Open(); err != nil && !errors.Is(err, os.ErrClosed) {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			if err := device.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		wg.Wait()
+
+		// Devices which were opened before they were closed are removed again
+		if _, err := netlink.LinkByName(device.deviceName); err == nil {
+			t.Fatalf("expected device %v to be removed", device.deviceName)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package devices
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrInvalidRoute = errors.New("invalid route")
+)
+
+// ParseAddresses parses addresses in CIDR notation, i.e. "10.0.0.1/24" or "fd00::1/64"
+func ParseAddresses(addresses []string) ([]*netlink.Addr, error) {
+	parsedAddresses := []*netlink.Addr{}
+	for _, address := range addresses {
+		parsedAddress, err := netlink.ParseAddr(address)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedAddresses = append(parsedAddresses, parsedAddress)
+	}
+
+	return parsedAddresses, nil
+}
+
+// ParseRoutes parses routes in the form "10.1.0.0/16" or "10.1.0.0/16 via 10.0.0.254"; the link is filled in once the device has been opened
+func ParseRoutes(routes []string) ([]*netlink.Route, error) {
+	parsedRoutes := []*netlink.Route{}
+	for _, route := range routes {
+		fields := strings.Fields(route)
+		if len(fields) != 1 && (len(fields) != 3 || fields[1] != "via") {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRoute, route)
+		}
+
+		_, destination, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		parsedRoute := &netlink.Route{Dst: destination}
+		if len(fields) == 3 {
+			if parsedRoute.Gw = net.ParseIP(fields[2]); parsedRoute.Gw == nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidRoute, route)
+			}
+		}
+
+		parsedRoutes = append(parsedRoutes, parsedRoute)
+	}
+
+	return parsedRoutes, nil
+}
+
+// DeriveHardwareAddress derives a stable, locally administered unicast hardware address from a node's identity, i.e. its public key;
+// the device name is included so that multiple devices of the same node get different addresses
+func DeriveHardwareAddress(identity []byte, deviceName string) net.HardwareAddr {
+	hash := sha256.Sum256(append(append([]byte{}, identity...), deviceName...))
+
+	hardwareAddress := net.HardwareAddr(hash[:6])
+	hardwareAddress[0] = (hardwareAddress[0] | 0x02) &^ 0x01
+
+	return hardwareAddress
+}
+
+// configureLink sets the MTU, brings the link up and adds the addresses and then the routes, as their gateways have to be reachable first
+func (d *queueDevice) configureLink(configure func(link netlink.Link) error) error {
+	link, err := d.handle.LinkByName(d.deviceName)
+	if err != nil {
+		return err
+	}
+
+	if configure != nil {
+		if err := configure(link); err != nil {
+			return err
+		}
+	}
+
+	if err := d.handle.LinkSetMTU(link, d.maximumTransmissionUnit); err != nil {
+		return err
+	}
+
+	if err := d.handle.LinkSetUp(link); err != nil {
+		return err
+	}
+
+	for _, address := range d.addresses {
+		if err := d.handle.AddrReplace(link, address); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range d.routes {
+		route.LinkIndex = link.Attrs().Index
+
+		if err := d.handle.RouteReplace(route); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deconfigureLink runs deconfigure, if set, and removes the routes and addresses again; they are already gone if the link has been removed
+func (d *queueDevice) deconfigureLink(deconfigure func(link netlink.Link) error) error {
+	link, err := d.handle.LinkByName(d.deviceName)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+
+		return err
+	}
+
+	errs := []error{}
+	if deconfigure != nil {
+		errs = append(errs, deconfigure(link))
+	}
+
+	for _, route := range d.routes {
+		if err := d.handle.RouteDel(route); err != nil && !errors.Is(err, unix.ESRCH) {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, address := range d.addresses {
+		if err := d.handle.AddrDel(link, address); err != nil && !errors.Is(err, unix.EADDRNOTAVAIL) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+package devices
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
+)
+
+const (
+	memoryQueueBufferSize = 1024
+)
+
+// MemoryDevice is backed by channels instead of a kernel device, so that frames can be injected into and captured from it,
+// i.e. to drive the forwarding pipeline in tests or from other programs without root
+type MemoryDevice struct {
+	framePool *pools.FramePool
+	in        []chan []byte
+	out       []chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryDevice creates a memory device with the given number of queues; framePool must hand out buffers which fit the injected frames
+func NewMemoryDevice(queues int, framePool *pools.FramePool) *MemoryDevice {
+	in := []chan []byte{}
+	out := []chan []byte{}
+	for queue := 0; queue < queues; queue++ {
+		in = append(in, make(chan []byte, memoryQueueBufferSize))
+		out = append(out, make(chan []byte, memoryQueueBufferSize))
+	}
+
+	return &MemoryDevice{framePool, in, out, make(chan struct{}), sync.Once{}}
+}
+
+func (d *MemoryDevice) Open() error {
+	return nil
+}
+
+func (d *MemoryDevice) Queues() int {
+	return len(d.in)
+}
+
+// Read returns the next injected frame in a buffer from the frame pool, which the caller should return to it once the frame has been sent;
+// ctx is unused, as memory devices don't have to be opened
+func (d *MemoryDevice) Read(ctx context.Context, queue int) ([]byte, error) {
+	select {
+	case rawFrame := <-d.in[queue]:
+		readFrame := d.framePool.GetBuffer()
+
+		return readFrame[:copy(readFrame, rawFrame)], nil
+	case <-d.done:
+		return nil, os.ErrClosed
+	}
+}
+
+// Write copies the frame, as callers may reuse it afterwards
+func (d *MemoryDevice) Write(ctx context.Context, queue int, rawFrame []byte) error {
+	select {
+	case d.out[queue] <- append([]byte{}, rawFrame...):
+		return nil
+	case <-d.done:
+		return os.ErrClosed
+	}
+}
+
+// Inject makes a frame available to Read, as if the kernel had sent it through the device
+func (d *MemoryDevice) Inject(queue int, rawFrame []byte) error {
+	select {
+	case d.in[queue] <- rawFrame:
+		return nil
+	case <-d.done:
+		return os.ErrClosed
+	}
+}
+
+// Capture returns the next frame which has been written to the device, or ctx.Err() if ctx is done first
+func (d *MemoryDevice) Capture(ctx context.Context, queue int) ([]byte, error) {
+	select {
+	case rawFrame := <-d.out[queue]:
+		return rawFrame, nil
+	case <-d.done:
+		return nil, os.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *MemoryDevice) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.done)
+	})
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package devices
+
+import (
+	"runtime"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// openHandle opens a netlink handle for the named network namespace, or for the current one if name is empty
+func openHandle(name string) (*netlink.Handle, error) {
+	if name == "" {
+		return netlink.NewHandle()
+	}
+
+	namespace, err := netns.GetFromName(name)
+	if err != nil {
+		return nil, err
+	}
+	defer namespace.Close()
+
+	return netlink.NewHandleAt(namespace)
+}
+
+// inNamespace calls f on a thread which has joined the named network namespace, or directly if name is empty
+func inNamespace(name string, f func() error) error {
+	if name == "" {
+		return f()
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		// The thread is never unlocked, so that it is terminated with the goroutine instead of being reused in the namespace
+		runtime.LockOSThread()
+
+		namespace, err := netns.GetFromName(name)
+		if err != nil {
+			errs <- err
+
+			return
+		}
+		defer namespace.Close()
+
+		if err := netns.Set(namespace); err != nil {
+			errs <- err
+
+			return
+		}
+
+		errs <- f()
+	}()
+
+	return <-errs
+}
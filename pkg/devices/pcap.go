@@ -0,0 +1,299 @@
+package devices
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/lifecycles"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+const (
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+	pcapVersionMajor      = 2
+	pcapVersionMinor      = 4
+	pcapHeaderLength      = 24
+	pcapRecordLength      = 16
+	pcapSnapshotLength    = 65535
+
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+)
+
+var (
+	ErrInvalidPcapFile     = errors.New("invalid pcap file")
+	ErrUnsupportedLinkType = errors.New("unsupported link type")
+	ErrFrameTooLarge       = errors.New("frame too large")
+)
+
+// PcapDevice replays frames from a pcap file and records the frames which are written to it to another one, i.e. to replay
+// captured traffic offline; frames are Ethernet frames or, for the IP payload type, raw IP packets
+type PcapDevice struct {
+	readPath    string
+	writePath   string
+	realtime    bool
+	payloadType proto.PayloadType
+	framePool   *pools.FramePool
+
+	reader     io.ReadCloser
+	byteOrder  binary.ByteOrder
+	resolution time.Duration
+	started    time.Time
+	firstFrame time.Duration
+
+	writeFile *os.File
+	writer    *bufio.Writer
+	writeLock sync.Mutex
+
+	lifecycle *lifecycles.Lifecycle
+	lock      sync.Mutex
+}
+
+// NewPcapDevice creates a pcap device; readPath and writePath may be empty to not replay or record frames respectively. If realtime is set,
+// frames are replayed with the gaps in which they were captured, otherwise as fast as they are read.
+func NewPcapDevice(readPath string, writePath string, realtime bool, payloadType proto.PayloadType, framePool *pools.FramePool) *PcapDevice {
+	return &PcapDevice{
+		readPath:    readPath,
+		writePath:   writePath,
+		realtime:    realtime,
+		payloadType: payloadType,
+		framePool:   framePool,
+		lifecycle:   lifecycles.NewLifecycle(),
+	}
+}
+
+func (d *PcapDevice) Open() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	select {
+	case <-d.lifecycle.Closed():
+		return os.ErrClosed
+	default:
+	}
+
+	linkType := uint32(linkTypeEthernet)
+	if d.payloadType == proto.PayloadType_IP {
+		linkType = linkTypeRaw
+	}
+
+	if d.readPath != "" {
+		file, err := os.Open(d.readPath)
+		if err != nil {
+			return err
+		}
+
+		reader := bufio.NewReader(file)
+
+		header := make([]byte, pcapHeaderLength)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			_ = file.Close()
+
+			return ErrInvalidPcapFile
+		}
+
+		// The magic number is written in the byte order of the host which captured the file
+		d.byteOrder, d.resolution = binary.ByteOrder(binary.LittleEndian), time.Microsecond
+		switch {
+		case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicMicroseconds:
+		case binary.BigEndian.Uint32(header[0:4]) == pcapMagicMicroseconds:
+			d.byteOrder = binary.BigEndian
+		case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicNanoseconds:
+			d.resolution = time.Nanosecond
+		case binary.BigEndian.Uint32(header[0:4]) == pcapMagicNanoseconds:
+			d.byteOrder, d.resolution = binary.BigEndian, time.Nanosecond
+		default:
+			_ = file.Close()
+
+			return ErrInvalidPcapFile
+		}
+
+		if d.byteOrder.Uint32(header[20:24]) != linkType {
+			_ = file.Close()
+
+			return ErrUnsupportedLinkType
+		}
+
+		d.reader = struct {
+			io.Reader
+			io.Closer
+		}{reader, file}
+	}
+
+	if d.writePath != "" {
+		file, err := os.Create(d.writePath)
+		if err != nil {
+			if d.reader != nil {
+				_ = d.reader.Close()
+			}
+
+			return err
+		}
+
+		header := make([]byte, 0, pcapHeaderLength)
+		header = binary.LittleEndian.AppendUint32(header, pcapMagicMicroseconds)
+		header = binary.LittleEndian.AppendUint16(header, pcapVersionMajor)
+		header = binary.LittleEndian.AppendUint16(header, pcapVersionMinor)
+		header = binary.LittleEndian.AppendUint32(header, 0) // Time zone offset
+		header = binary.LittleEndian.AppendUint32(header, 0) // Timestamp accuracy
+		header = binary.LittleEndian.AppendUint32(header, pcapSnapshotLength)
+		header = binary.LittleEndian.AppendUint32(header, linkType)
+
+		d.writeFile = file
+		d.writer = bufio.NewWriter(file)
+
+		if _, err := d.writer.Write(header); err != nil {
+			_ = file.Close()
+			if d.reader != nil {
+				_ = d.reader.Close()
+			}
+
+			return err
+		}
+	}
+
+	d.lifecycle.Open()
+
+	return nil
+}
+
+func (d *PcapDevice) Queues() int {
+	return 1
+}
+
+// Read returns the next replayed frame in a buffer from the frame pool, which the caller should return to it once the frame has been sent;
+// once all frames have been replayed, it blocks until the device is closed
+func (d *PcapDevice) Read(ctx context.Context, queue int) ([]byte, error) {
+	if err := d.WaitTillOpen(ctx); err != nil {
+		return nil, err
+	}
+
+	if d.reader == nil {
+		<-d.lifecycle.Closed()
+
+		return nil, os.ErrClosed
+	}
+
+	record := make([]byte, pcapRecordLength)
+	if _, err := io.ReadFull(d.reader, record); err != nil {
+		if err != io.EOF {
+			return nil, err
+		}
+
+		<-d.lifecycle.Closed()
+
+		return nil, os.ErrClosed
+	}
+
+	timestamp := time.Duration(d.byteOrder.Uint32(record[0:4]))*time.Second + time.Duration(d.byteOrder.Uint32(record[4:8]))*d.resolution
+	length := int(d.byteOrder.Uint32(record[8:12]))
+
+	readFrame := d.framePool.GetBuffer()
+	if length > len(readFrame) {
+		d.framePool.PutBuffer(readFrame)
+
+		if _, err := io.CopyN(io.Discard, d.reader, int64(length)); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrFrameTooLarge
+	}
+
+	if _, err := io.ReadFull(d.reader, readFrame[:length]); err != nil {
+		d.framePool.PutBuffer(readFrame)
+
+		return nil, err
+	}
+
+	if d.realtime {
+		if d.started.IsZero() {
+			d.started, d.firstFrame = time.Now(), timestamp
+		}
+
+		timer := time.NewTimer(time.Until(d.started.Add(timestamp - d.firstFrame)))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-d.lifecycle.Closed():
+			d.framePool.PutBuffer(readFrame)
+
+			return nil, os.ErrClosed
+		}
+	}
+
+	return readFrame[:length], nil
+}
+
+// Write records the frame, or drops it if no file to record to has been set
+func (d *PcapDevice) Write(ctx context.Context, queue int, rawFrame []byte) error {
+	if err := d.WaitTillOpen(ctx); err != nil {
+		return err
+	}
+
+	if d.writer == nil {
+		return nil
+	}
+
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+
+	select {
+	case <-d.lifecycle.Closed():
+		return os.ErrClosed
+	default:
+	}
+
+	now := time.Now()
+
+	record := make([]byte, 0, pcapRecordLength)
+	record = binary.LittleEndian.AppendUint32(record, uint32(now.Unix()))
+	record = binary.LittleEndian.AppendUint32(record, uint32(now.Nanosecond()/int(time.Microsecond)))
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(rawFrame)))
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(rawFrame)))
+
+	if _, err := d.writer.Write(record); err != nil {
+		return err
+	}
+
+	_, err := d.writer.Write(rawFrame)
+
+	return err
+}
+
+func (d *PcapDevice) Close() error {
+	if !d.lifecycle.Close() {
+		return nil
+	}
+
+	// Waits for a concurrent Open to finish, so that its files are closed as well
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	errs := []error{}
+	if d.reader != nil {
+		errs = append(errs, d.reader.Close())
+	}
+
+	if d.writer != nil {
+		d.writeLock.Lock()
+		defer d.writeLock.Unlock()
+
+		errs = append(errs, d.writer.Flush(), d.writeFile.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// WaitTillOpen blocks until the device has been opened; it returns os.ErrClosed if the device has been closed
+func (d *PcapDevice) WaitTillOpen(ctx context.Context) error {
+	return d.lifecycle.Wait(ctx)
+}
@@ -1,69 +1,110 @@
 package devices
 
 import (
-	"github.com/songgao/water"
+	"net"
+
+	"github.com/pojntfx/gloeth/pkg/pools"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Ethernet header including an 802.1Q tag, which the MTU doesn't account for
+	EthernetOverhead = 18
+
+	// Prefixed to every frame if offloads are enabled
+	VirtioNetHeaderLength = 10
+
+	// Super-frames can be as large as the largest IP packet
+	MaximumOffloadFrameLength = VirtioNetHeaderLength + EthernetOverhead + 65535
 )
 
 type TAPDevice struct {
-	deviceName              string
-	maximumTransmissionUnit int
-	device                  *water.Interface
+	*queueDevice
+	hardwareAddress net.HardwareAddr
+	bridgeName      string
+	createBridge    bool
 }
 
-func NewTAPDevice(deviceName string, maximumTransmissionUnit int) *TAPDevice {
-	return &TAPDevice{deviceName, maximumTransmissionUnit, nil}
-}
+// TAPDeviceConfig configures a TAP device
+type TAPDeviceConfig struct {
+	DeviceName              string
+	MaximumTransmissionUnit int
+	Queues                  int
 
-func (d *TAPDevice) Open() error {
-	device, err := water.New(water.Config{
-		DeviceType: water.TAP,
-		PlatformSpecificParams: water.PlatformSpecificParams{
-			Name: d.deviceName,
-		},
-	})
-	if err != nil {
-		return err
-	}
+	// If set, the kernel may hand out TCP super-frames and frames with partial checksums, and every frame is prefixed by a virtio net header
+	Offload bool
 
-	link, err := netlink.LinkByName(d.deviceName)
-	if err != nil {
-		return err
-	}
+	// Added once the device is up and removed when it is closed
+	Addresses []*netlink.Addr
+	Routes    []*netlink.Route
 
-	if err := netlink.LinkSetMTU(link, d.maximumTransmissionUnit); err != nil {
-		return err
-	}
+	// If nil, the kernel picks a random one
+	HardwareAddress net.HardwareAddr
 
-	if err := netlink.LinkSetUp(link); err != nil {
-		return err
-	}
+	// If set, the device is attached to this bridge, which is created first if it doesn't exist and CreateBridge is set
+	BridgeName   string
+	CreateBridge bool
 
-	d.device = device
+	// If set, the device is created in this existing named network namespace
+	Namespace string
+}
 
-	return nil
+// NewTAPDevice creates a TAP device with the configured number of queues, each of which can be read from and written to concurrently.
+// framePool must hand out buffers of at least MaximumTransmissionUnit + EthernetOverhead bytes, or MaximumOffloadFrameLength bytes if Offload is set.
+func NewTAPDevice(config TAPDeviceConfig, framePool *pools.FramePool) *TAPDevice {
+	return &TAPDevice{newQueueDevice(config.DeviceName, config.MaximumTransmissionUnit, config.Queues, unix.IFF_TAP, config.Offload, config.Addresses, config.Routes, config.Namespace, framePool), config.HardwareAddress, config.BridgeName, config.CreateBridge}
 }
 
-func (d *TAPDevice) Write(rawFrame []byte) error {
-	d.waitTillOpen()
+func (d *TAPDevice) Open() error {
+	// Setting the hardware address before the link is up keeps the IPv6 link-local address derived from it
+	return d.open(func(link netlink.Link) error {
+		if d.hardwareAddress != nil {
+			if err := d.handle.LinkSetHardwareAddr(link, d.hardwareAddress); err != nil {
+				return err
+			}
+		}
+
+		if d.bridgeName == "" {
+			return nil
+		}
+
+		return d.attachToBridge(link)
+	})
+}
 
-	_, err := d.device.Write(rawFrame)
+// Close detaches the device from the bridge; the bridge itself is kept, even if it was created, as other interfaces may have been attached to it since
+func (d *TAPDevice) Close() error {
+	if d.bridgeName == "" {
+		return d.queueDevice.Close()
+	}
 
-	return err
+	return d.close(d.detachFromBridge)
 }
 
-func (d *TAPDevice) Read() ([]byte, error) {
-	d.waitTillOpen()
+func (d *TAPDevice) attachToBridge(link netlink.Link) error {
+	bridge, err := d.handle.LinkByName(d.bridgeName)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); !ok || !d.createBridge {
+			return err
+		}
 
-	readFrame := make([]byte, d.maximumTransmissionUnit)
+		if err := d.handle.LinkAdd(&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: d.bridgeName}}); err != nil {
+			return err
+		}
 
-	_, err := d.device.Read(readFrame)
+		if bridge, err = d.handle.LinkByName(d.bridgeName); err != nil {
+			return err
+		}
 
-	return readFrame, err
-}
+		if err := d.handle.LinkSetUp(bridge); err != nil {
+			return err
+		}
+	}
 
-func (d *TAPDevice) waitTillOpen() {
-	for d.device == nil {
+	return d.handle.LinkSetMasterByIndex(link, bridge.Attrs().Index)
+}
 
-	}
+func (d *TAPDevice) detachFromBridge(link netlink.Link) error {
+	return d.handle.LinkSetNoMaster(link)
 }
@@ -0,0 +1,17 @@
+package devices
+
+import (
+	"github.com/pojntfx/gloeth/pkg/pools"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type TUNDevice struct {
+	*queueDevice
+}
+
+// NewTUNDevice creates a TUN device, which reads and writes IP packets instead of Ethernet frames, with the given number of queues,
+// optionally in an existing named network namespace; framePool must hand out buffers of at least maximumTransmissionUnit bytes
+func NewTUNDevice(deviceName string, maximumTransmissionUnit int, queues int, addresses []*netlink.Addr, routes []*netlink.Route, namespace string, framePool *pools.FramePool) *TUNDevice {
+	return &TUNDevice{newQueueDevice(deviceName, maximumTransmissionUnit, queues, unix.IFF_TUN, false, addresses, routes, namespace, framePool)}
+}
@@ -0,0 +1,117 @@
+package dialers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	ErrUnsupportedProxyScheme = errors.New("unsupported proxy scheme")
+)
+
+type ProxyDialer struct {
+	proxyURL *url.URL
+}
+
+func NewProxyDialer(proxyURL string) (*ProxyDialer, error) {
+	parsedProxyURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsedProxyURL.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedProxyScheme, parsedProxyURL.Scheme)
+	}
+
+	return &ProxyDialer{parsedProxyURL}, nil
+}
+
+func (d *ProxyDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if d.proxyURL.Scheme == "http" {
+		return d.dialHTTP(ctx, network, address)
+	}
+
+	return d.dialSOCKS5(ctx, network, address)
+}
+
+func (d *ProxyDialer) dialSOCKS5(ctx context.Context, network string, address string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+
+		auth = &proxy.Auth{
+			User:     d.proxyURL.User.Username(),
+			Password: password,
+		}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", d.proxyURL.Host, auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+
+	return dialer.(proxy.ContextDialer).DialContext(ctx, network, address)
+}
+
+func (d *ProxyDialer) dialHTTP(ctx context.Context, network string, address string) (net.Conn, error) {
+	connection, err := (&net.Dialer{}).DialContext(ctx, network, d.proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+
+		request.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(d.proxyURL.User.Username()+":"+password)))
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := connection.SetDeadline(deadline); err != nil {
+			_ = connection.Close()
+
+			return nil, err
+		}
+		defer connection.SetDeadline(time.Time{})
+	}
+
+	if err := request.Write(connection); err != nil {
+		_ = connection.Close()
+
+		return nil, err
+	}
+
+	// The proxy won't send anything after its response until we start the TLS handshake, so nothing can get lost in the buffer
+	response, err := http.ReadResponse(bufio.NewReader(connection), request)
+	if err != nil {
+		_ = connection.Close()
+
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		_ = connection.Close()
+
+		return nil, fmt.Errorf("could not connect through HTTP proxy: %v", response.Status)
+	}
+
+	return connection, nil
+}
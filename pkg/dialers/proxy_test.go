@@ -0,0 +1,267 @@
+package dialers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testUser     = "user"
+	testPassword = "password"
+)
+
+// listen serves each connection to the listener with handle until the test ends
+func listen(t *testing.T, handle func(connection net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer connection.Close()
+
+				handle(connection)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// newEchoServer returns the address of a server which echoes everything back
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+
+	return listen(t, func(connection net.Conn) {
+		_, _ = io.Copy(connection, connection)
+	})
+}
+
+// relay connects the client to the target until either of them closes the connection
+func relay(client net.Conn, target net.Conn) {
+	go func() {
+		_, _ = io.Copy(target, client)
+		_ = target.Close()
+	}()
+
+	_, _ = io.Copy(client, target)
+}
+
+// newHTTPProxy returns the address of an HTTP proxy which tunnels CONNECT requests; it requires credentials if authenticate is set
+func newHTTPProxy(t *testing.T, authenticate bool) string {
+	t.Helper()
+
+	return listen(t, func(connection net.Conn) {
+		request, err := http.ReadRequest(bufio.NewReader(connection))
+		if err != nil {
+			return
+		}
+
+		reply := func(status int) {
+			_, _ = io.WriteString(connection, "HTTP/1.1 "+strconv.Itoa(status)+" "+http.StatusText(status)+"\r\n\r\n")
+		}
+
+		if request.Method != http.MethodConnect {
+			reply(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if authenticate && request.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(testUser+":"+testPassword)) {
+			reply(http.StatusProxyAuthRequired)
+
+			return
+		}
+
+		target, err := net.Dial("tcp", request.Host)
+		if err != nil {
+			reply(http.StatusBadGateway)
+
+			return
+		}
+		defer target.Close()
+
+		reply(http.StatusOK)
+
+		relay(connection, target)
+	})
+}
+
+// newSOCKS5Proxy returns the address of a SOCKS5 proxy which only supports CONNECT requests to IPv4 addresses, as described in RFC 1928;
+// it requires credentials as described in RFC 1929 if authenticate is set
+func newSOCKS5Proxy(t *testing.T, authenticate bool) string {
+	t.Helper()
+
+	return listen(t, func(connection net.Conn) {
+		reader := bufio.NewReader(connection)
+
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(reader, greeting); err != nil {
+			return
+		}
+
+		if _, err := io.ReadFull(reader, make([]byte, greeting[1])); err != nil {
+			return
+		}
+
+		if !authenticate {
+			_, _ = connection.Write([]byte{0x05, 0x00})
+		} else {
+			_, _ = connection.Write([]byte{0x05, 0x02})
+
+			readString := func() string {
+				length, err := reader.ReadByte()
+				if err != nil {
+					return ""
+				}
+
+				value := make([]byte, length)
+				if _, err := io.ReadFull(reader, value); err != nil {
+					return ""
+				}
+
+				return string(value)
+			}
+
+			if _, err := reader.ReadByte(); err != nil {
+				return
+			}
+
+			if user, password := readString(), readString(); user != testUser || password != testPassword {
+				_, _ = connection.Write([]byte{0x01, 0x01})
+
+				return
+			}
+
+			_, _ = connection.Write([]byte{0x01, 0x00})
+		}
+
+		request := make([]byte, 10)
+		if _, err := io.ReadFull(reader, request); err != nil || request[1] != 0x01 || request[3] != 0x01 {
+			return
+		}
+
+		target, err := net.Dial("tcp", net.JoinHostPort(net.IP(request[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(request[8:10])))))
+		if err != nil {
+			// Connection refused
+			_, _ = connection.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+			return
+		}
+		defer target.Close()
+
+		_, _ = connection.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+		relay(connection, target)
+	})
+}
+
+// closedAddress returns an address which nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return address
+}
+
+func TestProxyDialerDialContext(t *testing.T) {
+	echoAddress := newEchoServer(t)
+
+	tests := []struct {
+		name          string
+		newProxy      func(t *testing.T, authenticate bool) string
+		scheme        string
+		authenticate  bool
+		credentials   string
+		targetAddress string
+		valid         bool
+	}{
+		{"http", newHTTPProxy, "http", false, "", echoAddress, true},
+		{"http with credentials", newHTTPProxy, "http", true, testUser + ":" + testPassword + "@", echoAddress, true},
+		{"http without credentials", newHTTPProxy, "http", true, "", echoAddress, false},
+		{"http with invalid credentials", newHTTPProxy, "http", true, testUser + ":invalid@", echoAddress, false},
+		{"http to an unreachable target", newHTTPProxy, "http", false, "", closedAddress(t), false},
+		{"socks5", newSOCKS5Proxy, "socks5", false, "", echoAddress, true},
+		{"socks5 with credentials", newSOCKS5Proxy, "socks5", true, testUser + ":" + testPassword + "@", echoAddress, true},
+		{"socks5 with invalid credentials", newSOCKS5Proxy, "socks5", true, testUser + ":invalid@", echoAddress, false},
+		{"socks5 to an unreachable target", newSOCKS5Proxy, "socks5", false, "", closedAddress(t), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dialer, err := NewProxyDialer(test.scheme + "://" + test.credentials + test.newProxy(t, test.authenticate))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			connection, err := dialer.DialContext(ctx, "tcp", test.targetAddress)
+			if !test.valid {
+				if err == nil {
+					_ = connection.Close()
+
+					t.Fatal("expected dialing to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer connection.Close()
+
+			// The connection is tunneled to the target without anything lost or added by the proxy
+			if _, err := io.WriteString(connection, "hello"); err != nil {
+				t.Fatal(err)
+			}
+
+			echo := make([]byte, len("hello"))
+			if _, err := io.ReadFull(connection, echo); err != nil {
+				t.Fatal(err)
+			}
+
+			if string(echo) != "hello" {
+				t.Fatalf("expected %q, got %q", "hello", echo)
+			}
+		})
+	}
+}
+
+func TestNewProxyDialerRejectsUnsupportedSchemes(t *testing.T) {
+	if _, err := NewProxyDialer("ftp://localhost:21"); !errors.Is(err, ErrUnsupportedProxyScheme) {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedProxyScheme, err)
+	}
+}
@@ -0,0 +1,316 @@
+package encryptors
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/parsers"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/validators"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	keyDerivationInfo = "gloeth end-to-end v2"
+	counterLength     = 8
+)
+
+var (
+	ErrMissingSender      = errors.New("frame is not end-to-end encrypted")
+	ErrInvalidRecipient   = errors.New("frame is not addressed to this peer")
+	ErrCiphertextTooShort = errors.New("ciphertext too short")
+	ErrUnknownSender      = errors.New("frame is from a peer which isn't allowed")
+	ErrReplayedFrame      = errors.New("frame has been replayed")
+)
+
+type EndToEndEncryptor struct {
+	privateKey       *ecdh.PrivateKey
+	payloadType      proto.PayloadType
+	publicKey        []byte
+	allowedPeers     map[string]struct{}
+	peers            [][]byte
+	sealers          map[string]cipher.AEAD
+	openers          map[string]cipher.AEAD
+	addresses        map[string][]byte
+	counter          uint64
+	replayValidators map[string]*validators.ReplayValidator
+	lock             sync.Mutex
+}
+
+// NewEndToEndEncryptor creates an encryptor; payloadType determines whether peers are learned by their hardware or IP addresses.
+// Frames are only exchanged with allowedPeers, so that the hub can't insert itself by announcing its own public key.
+func NewEndToEndEncryptor(privateKey *ecdh.PrivateKey, payloadType proto.PayloadType, allowedPeers [][]byte) *EndToEndEncryptor {
+	allowed := make(map[string]struct{})
+	for _, publicKey := range allowedPeers {
+		allowed[string(publicKey)] = struct{}{}
+	}
+
+	return &EndToEndEncryptor{
+		privateKey:   privateKey,
+		payloadType:  payloadType,
+		publicKey:    privateKey.PublicKey().Bytes(),
+		allowedPeers: allowed,
+		sealers:      make(map[string]cipher.AEAD),
+		openers:      make(map[string]cipher.AEAD),
+		addresses:    make(map[string][]byte),
+		// Counters start at the current time so that they keep increasing across restarts, which peers would otherwise reject as replays
+		counter:          uint64(time.Now().UnixNano()),
+		replayValidators: make(map[string]*validators.ReplayValidator),
+	}
+}
+
+func (e *EndToEndEncryptor) PublicKey() []byte {
+	return e.publicKey
+}
+
+// SetPeers replaces the peers which frames are sealed for; it is called whenever the hub announces a change.
+// Peers which aren't allowed are ignored.
+func (e *EndToEndEncryptor) SetPeers(publicKeys [][]byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	peers := [][]byte{}
+	known := make(map[string]struct{})
+	for _, publicKey := range publicKeys {
+		if _, ok := e.allowedPeers[string(publicKey)]; !ok || bytes.Equal(publicKey, e.publicKey) {
+			continue
+		}
+
+		peers = append(peers, publicKey)
+		known[string(publicKey)] = struct{}{}
+	}
+
+	for address, publicKey := range e.addresses {
+		if _, ok := known[string(publicKey)]; !ok {
+			delete(e.addresses, address)
+		}
+	}
+
+	for _, cache := range []map[string]cipher.AEAD{e.sealers, e.openers} {
+		for publicKey := range cache {
+			if _, ok := known[publicKey]; !ok {
+				delete(cache, publicKey)
+			}
+		}
+	}
+
+	e.peers = peers
+}
+
+// Seal encrypts a frame for the peer which owns its destination address, or for every
+// peer if the destination is a broadcast/multicast address or hasn't been learned yet
+func (e *EndToEndEncryptor) Seal(rawFrame []byte) ([]*proto.FrameMessage, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	recipients := e.peers
+	if destination, _, ok := parsers.Addresses(e.payloadType, rawFrame); ok && parsers.IsUnicast(e.payloadType, destination) {
+		if publicKey, ok := e.addresses[string(destination)]; ok {
+			recipients = [][]byte{publicKey}
+		}
+	}
+
+	// The hub could otherwise replay sealed frames, as the sequence numbers of streams only protect each hop
+	e.counter++
+
+	plaintext := make([]byte, counterLength+len(rawFrame))
+	binary.BigEndian.PutUint64(plaintext, e.counter)
+	copy(plaintext[counterLength:], rawFrame)
+
+	frames := []*proto.FrameMessage{}
+	for _, recipient := range recipients {
+		sealer, err := e.aead(e.sealers, e.publicKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		nonce := make([]byte, sealer.NonceSize(), sealer.NonceSize()+len(plaintext)+sealer.Overhead())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, &proto.FrameMessage{
+			Content:   sealer.Seal(nonce, nonce, plaintext, nil),
+			Sender:    e.publicKey,
+			Recipient: recipient,
+		})
+	}
+
+	return frames, nil
+}
+
+func (e *EndToEndEncryptor) Open(frame *proto.FrameMessage) ([]byte, error) {
+	if len(frame.Sender) == 0 {
+		return nil, ErrMissingSender
+	}
+
+	if !bytes.Equal(frame.Recipient, e.publicKey) {
+		return nil, ErrInvalidRecipient
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.allowedPeers[string(frame.Sender)]; !ok {
+		return nil, ErrUnknownSender
+	}
+
+	opener, err := e.aead(e.openers, frame.Sender, e.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frame.Content) < opener.NonceSize() {
+		return nil, ErrCiphertextTooShort
+	}
+
+	plaintext, err := opener.Open(nil, frame.Content[:opener.NonceSize()], frame.Content[opener.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plaintext) < counterLength {
+		return nil, ErrCiphertextTooShort
+	}
+
+	// Validators are kept when peers leave, so that their frames can't be replayed once they rejoin; the allowed peers bound their number
+	replayValidator, ok := e.replayValidators[string(frame.Sender)]
+	if !ok {
+		replayValidator = validators.NewReplayValidator()
+
+		e.replayValidators[string(frame.Sender)] = replayValidator
+	}
+
+	if !replayValidator.Validate(binary.BigEndian.Uint64(plaintext)) {
+		return nil, ErrReplayedFrame
+	}
+
+	rawFrame := plaintext[counterLength:]
+
+	// Only learn once the frame has been authenticated so that the hub can't redirect traffic
+	if _, source, ok := parsers.Addresses(e.payloadType, rawFrame); ok && parsers.IsUnicast(e.payloadType, source) {
+		e.addresses[string(source)] = append([]byte{}, frame.Sender...)
+	}
+
+	return rawFrame, nil
+}
+
+// aead derives a key per direction from the X25519 shared secret, so the sender and recipient never share a nonce space
+func (e *EndToEndEncryptor) aead(cache map[string]cipher.AEAD, sender []byte, recipient []byte) (cipher.AEAD, error) {
+	peer := recipient
+	if bytes.Equal(peer, e.publicKey) {
+		peer = sender
+	}
+
+	if aead, ok := cache[string(peer)]; ok {
+		return aead, nil
+	}
+
+	peerPublicKey, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := e.privateKey.ECDH(peerPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info := append(append([]byte(keyDerivationInfo), sender...), recipient...)
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, nil, info), key); err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	cache[string(peer)] = aead
+
+	return aead, nil
+}
+
+// ReadOrCreatePrivateKey reads a base64-encoded X25519 private key, generating it if it doesn't exist yet
+func ReadOrCreatePrivateKey(path string) (*ecdh.PrivateKey, error) {
+	rawPrivateKey, err := os.ReadFile(path)
+	if err == nil {
+		decodedPrivateKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(rawPrivateKey)))
+		if err != nil {
+			return nil, err
+		}
+
+		return ecdh.X25519().NewPrivateKey(decodedPrivateKey)
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(privateKey.Bytes())+"\n"), 0600); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// ReadPublicKeys reads one base64-encoded public key per line, ignoring empty lines and comments
+func ReadPublicKeys(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	publicKeys := [][]byte{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		publicKey, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := ecdh.X25519().NewPublicKey(publicKey); err != nil {
+			return nil, err
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return publicKeys, nil
+}
@@ -0,0 +1,100 @@
+package encryptors_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/pojntfx/gloeth/pkg/encryptors"
+	"github.com/pojntfx/gloeth/pkg/fixtures"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+func newPrivateKey(t *testing.T) *ecdh.PrivateKey {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return privateKey
+}
+
+func TestEndToEndEncryptorIgnoresPeersWhichArentAllowed(t *testing.T) {
+	alice, bob, mallory := newPrivateKey(t), newPrivateKey(t), newPrivateKey(t)
+	allowedPeers := [][]byte{alice.PublicKey().Bytes(), bob.PublicKey().Bytes()}
+
+	aliceEncryptor := encryptors.NewEndToEndEncryptor(alice, proto.PayloadType_ETHERNET, allowedPeers)
+	bobEncryptor := encryptors.NewEndToEndEncryptor(bob, proto.PayloadType_ETHERNET, allowedPeers)
+	malloryEncryptor := encryptors.NewEndToEndEncryptor(mallory, proto.PayloadType_ETHERNET, append(allowedPeers, mallory.PublicKey().Bytes()))
+
+	// The hub announces its own public key alongside the real peers
+	bobEncryptor.SetPeers([][]byte{alice.PublicKey().Bytes(), bob.PublicKey().Bytes(), mallory.PublicKey().Bytes()})
+
+	broadcast := fixtures.NewEthernetFrame([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte{0x02, 0, 0, 0, 0, 0x02}, 1, 2, 3)
+
+	frames, err := bobEncryptor.Seal(broadcast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 1 || !bytes.Equal(frames[0].Recipient, alice.PublicKey().Bytes()) {
+		t.Fatalf("expected broadcasts to only be sealed for alice, got %v frames", len(frames))
+	}
+
+	// The hub replies under its own public key with a spoofed source address
+	malloryEncryptor.SetPeers([][]byte{alice.PublicKey().Bytes()})
+
+	spoofed, err := malloryEncryptor.Seal(fixtures.NewEthernetFrame([]byte{0x02, 0, 0, 0, 0, 0x01}, []byte{0x02, 0, 0, 0, 0, 0x02}, 1, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := aliceEncryptor.Open(spoofed[0]); !errors.Is(err, encryptors.ErrUnknownSender) {
+		t.Fatalf("expected %v, got %v", encryptors.ErrUnknownSender, err)
+	}
+}
+
+func TestEndToEndEncryptorRejectsReplayedFrames(t *testing.T) {
+	alice, bob := newPrivateKey(t), newPrivateKey(t)
+	allowedPeers := [][]byte{alice.PublicKey().Bytes(), bob.PublicKey().Bytes()}
+
+	aliceEncryptor := encryptors.NewEndToEndEncryptor(alice, proto.PayloadType_ETHERNET, allowedPeers)
+	bobEncryptor := encryptors.NewEndToEndEncryptor(bob, proto.PayloadType_ETHERNET, allowedPeers)
+
+	bobEncryptor.SetPeers(allowedPeers)
+
+	rawFrame := fixtures.NewEthernetFrame([]byte{0x02, 0, 0, 0, 0, 0x01}, []byte{0x02, 0, 0, 0, 0, 0x02}, 1, 2, 3)
+
+	frames, err := bobEncryptor.Seal(rawFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := aliceEncryptor.Open(frames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(opened, rawFrame) {
+		t.Fatalf("expected %x, got %x", rawFrame, opened)
+	}
+
+	if _, err := aliceEncryptor.Open(frames[0]); !errors.Is(err, encryptors.ErrReplayedFrame) {
+		t.Fatalf("expected %v, got %v", encryptors.ErrReplayedFrame, err)
+	}
+
+	// A restarted sender continues with higher counters
+	restartedBobEncryptor := encryptors.NewEndToEndEncryptor(bob, proto.PayloadType_ETHERNET, allowedPeers)
+	restartedBobEncryptor.SetPeers(allowedPeers)
+
+	frames, err = restartedBobEncryptor.Seal(rawFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := aliceEncryptor.Open(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package fixtures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/converters"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/services"
+	"github.com/pojntfx/gloeth/pkg/tables"
+	"github.com/pojntfx/gloeth/pkg/validators"
+)
+
+const (
+	PreSharedKey = "test-pre-shared-key"
+)
+
+// NewEthernetFrame returns a frame from source to destination with the payload
+func NewEthernetFrame(destination []byte, source []byte, payload ...byte) []byte {
+	return append(append(append(append([]byte{}, destination...), source...), 0x08, 0x00), payload...)
+}
+
+// NewFrameService creates a service which accepts PreSharedKey and lets peers choose from compressions
+func NewFrameService(t testing.TB, frameConverter *converters.FrameConverter, maximumBatchLength int, compressions []proto.Compression) *services.FrameService {
+	t.Helper()
+
+	preSharedKeyHash, err := validators.HashPreSharedKey(PreSharedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return services.NewFrameService(
+		tables.NewForwardingTable(time.Minute, 1024),
+		tables.NewForwardingTable(time.Minute, 1024),
+		validators.NewIdentityValidator(nil),
+		validators.NewPreSharedKeyValidator([][]byte{preSharedKeyHash}),
+		frameConverter,
+		services.FrameServiceConfig{
+			MaximumBatchFrames: 32,
+			MaximumBatchLength: maximumBatchLength,
+			Compressions:       compressions,
+			PayloadType:        proto.PayloadType_ETHERNET,
+		},
+	)
+}
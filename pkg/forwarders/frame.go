@@ -0,0 +1,494 @@
+package forwarders
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/batchers"
+	"github.com/pojntfx/gloeth/pkg/clients"
+	"github.com/pojntfx/gloeth/pkg/converters"
+	"github.com/pojntfx/gloeth/pkg/devices"
+	"github.com/pojntfx/gloeth/pkg/encryptors"
+	"github.com/pojntfx/gloeth/pkg/hashers"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/servers"
+	"github.com/pojntfx/gloeth/pkg/services"
+	"github.com/pojntfx/gloeth/pkg/transports"
+)
+
+const (
+	deviceQueueBufferSize = 1024
+	shutdownTimeout       = 15 * time.Second
+)
+
+var (
+	ErrShutdownTimeout = errors.New("could not shut down in time")
+)
+
+// FrameForwarder forwards frames between the queues of a network device and either the spokes which connect to the hub's
+// frame service or, with one frame client per queue, the hub which a spoke connects to
+type FrameForwarder struct {
+	device            devices.Device
+	frameConverter    *converters.FrameConverter
+	endToEndEncryptor *encryptors.EndToEndEncryptor
+	offload           bool
+	payloadType       proto.PayloadType
+	debug             bool
+
+	frameService *services.FrameService
+	frameServer  *servers.FrameServer
+
+	frameClients  []*clients.FrameClient
+	frameBatchers []*batchers.FrameBatcher
+}
+
+// NewHubFrameForwarder creates a forwarder which serves frameService on transport; endToEndEncryptor is optional
+func NewHubFrameForwarder(device devices.Device, transport transports.Transport, frameService *services.FrameService, frameConverter *converters.FrameConverter, endToEndEncryptor *encryptors.EndToEndEncryptor, offload bool, payloadType proto.PayloadType, debug bool) *FrameForwarder {
+	return &FrameForwarder{
+		device:            device,
+		frameConverter:    frameConverter,
+		endToEndEncryptor: endToEndEncryptor,
+		offload:           offload,
+		payloadType:       payloadType,
+		debug:             debug,
+		frameService:      frameService,
+		frameServer:       servers.NewFrameServer(transport, frameService),
+	}
+}
+
+// SpokeFrameForwarderConfig configures the frame clients and batchers of a spoke
+type SpokeFrameForwarderConfig struct {
+	PreSharedKey string
+
+	// The local end-to-end encryption key; nil if end-to-end encryption is disabled
+	PublicKey []byte
+
+	// Shared by all frame clients, so that the hub treats them as one port
+	PeerID []byte
+
+	// The payload compressions which are offered to the hub
+	Compressions []proto.Compression
+
+	Offload     bool
+	PayloadType proto.PayloadType
+
+	BatchMaximumFrames int
+	BatchMaximumDelay  time.Duration
+
+	Debug bool
+}
+
+// NewSpokeFrameForwarder creates a forwarder with one frame client and batcher on transport per device queue; endToEndEncryptor is optional
+func NewSpokeFrameForwarder(device devices.Device, transport transports.Transport, frameConverter *converters.FrameConverter, endToEndEncryptor *encryptors.EndToEndEncryptor, framePool *pools.FramePool, config SpokeFrameForwarderConfig) *FrameForwarder {
+	frameClients := []*clients.FrameClient{}
+	frameBatchers := []*batchers.FrameBatcher{}
+	for queue := 0; queue < device.Queues(); queue++ {
+		frameClient := clients.NewFrameClient(transport, config.PreSharedKey, config.PublicKey, config.PeerID, config.Compressions, config.Offload, config.PayloadType)
+
+		frameClients = append(frameClients, frameClient)
+		frameBatchers = append(frameBatchers, batchers.NewFrameBatcher(config.BatchMaximumFrames, transport.MaximumBatchLength(), config.BatchMaximumDelay, frameClient, framePool))
+	}
+
+	return &FrameForwarder{
+		device:            device,
+		frameConverter:    frameConverter,
+		endToEndEncryptor: endToEndEncryptor,
+		offload:           config.Offload,
+		payloadType:       config.PayloadType,
+		debug:             config.Debug,
+		frameClients:      frameClients,
+		frameBatchers:     frameBatchers,
+	}
+}
+
+// Open opens the network device and the frame server or clients and forwards frames until ctx is done or one of them fails;
+// it then shuts down gracefully and returns the first error, or ErrShutdownTimeout if shutting down takes too long
+func (f *FrameForwarder) Open(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var (
+		failure     error
+		failureLock sync.Mutex
+	)
+
+	// Failures after startup shut down gracefully as well, so that the network device doesn't outlive the process
+	fail := func(message string, err error) {
+		log.Println(message, err)
+
+		failureLock.Lock()
+		if failure == nil {
+			failure = err
+		}
+		failureLock.Unlock()
+
+		stop()
+	}
+
+	genesis := f.frameService != nil
+
+	if genesis {
+		go func() {
+			log.Println("Opening frame server")
+
+			if err := f.frameServer.Open(); err != nil {
+				fail("could not open frame server", err)
+			}
+		}()
+	} else {
+		for _, frameClient := range f.frameClients {
+			go func(frameClient *clients.FrameClient) {
+				log.Println("Opening frame client")
+
+				if err := frameClient.Open(); err != nil && !errors.Is(err, os.ErrClosed) {
+					fail("could not open frame client", err)
+				}
+			}(frameClient)
+		}
+	}
+
+	go func() {
+		log.Println("Opening network device")
+
+		if err := f.device.Open(); err != nil && !errors.Is(err, os.ErrClosed) {
+			fail("could not open network device", err)
+		}
+	}()
+
+	var deviceReaders, deviceWriters, frameReaders sync.WaitGroup
+
+	// Frames are distributed to the network device's queues by flow, so that frames of the same flow don't get reordered
+	deviceFrames := []chan []byte{}
+	for queue := 0; queue < f.device.Queues(); queue++ {
+		frames := make(chan []byte, deviceQueueBufferSize)
+
+		deviceFrames = append(deviceFrames, frames)
+
+		deviceWriters.Add(1)
+
+		go func(queue int, frames chan []byte) {
+			defer deviceWriters.Done()
+
+			for rawFrame := range frames {
+				if err := f.device.Write(ctx, queue, rawFrame); err != nil {
+					log.Println("could not write to network device, dropping frame", err)
+
+					continue
+				}
+			}
+		}(queue, frames)
+	}
+
+	// The virtio net header differs between frames of the same flow, so it mustn't be hashed
+	hashOffset := 0
+	if f.offload {
+		hashOffset = devices.VirtioNetHeaderLength
+	}
+
+	hashFlow := hashers.HashFlow
+	if f.payloadType == proto.PayloadType_IP {
+		hashFlow = hashers.HashPacket
+	}
+
+	writeToDevice := func(rawFrame []byte) {
+		deviceFrames[hashFlow(rawFrame[hashOffset:])%uint32(len(deviceFrames))] <- rawFrame
+	}
+
+	for queue := 0; queue < f.device.Queues(); queue++ {
+		deviceReaders.Add(1)
+
+		go func(queue int) {
+			defer deviceReaders.Done()
+
+			log.Printf("Reading from network device queue %v", queue)
+
+			// Reused for every frame to avoid allocations
+			frames := []*proto.FrameMessage{}
+
+			for {
+				rawFrame, err := f.device.Read(ctx, queue)
+				if err != nil {
+					// Devices which haven't been opened before the shutdown return the context's error
+					if errors.Is(err, os.ErrClosed) || errors.Is(err, ctx.Err()) {
+						return
+					}
+
+					log.Println("could not read from network device, dropping frame", err)
+
+					continue
+				}
+
+				// The frame service compresses and segments for each session itself
+				compression, offload := proto.Compression_NONE, true
+				if !genesis {
+					compression, offload = f.frameClients[queue].Compression(), f.frameClients[queue].Offload()
+				}
+
+				frames, err = f.frameConverter.ToExternal(frames[:0], rawFrame, compression, offload)
+				if err != nil {
+					log.Println("could not convert internal frame to external frame, dropping frame", err)
+
+					continue
+				}
+
+				for _, frame := range frames {
+					if genesis {
+						if f.debug {
+							log.Println("Writing frame from network device to frame service")
+						}
+
+						if err := f.frameService.Write(frame); err != nil {
+							if errors.Is(err, os.ErrClosed) {
+								break
+							}
+
+							log.Println("could not write to frame service, dropping frame and continuing in 250ms", err)
+
+							time.Sleep(time.Millisecond * 250)
+
+							break
+						}
+					} else {
+						if f.debug {
+							log.Println("Writing frame from network device to frame client")
+						}
+
+						// Each queue has its own stream, and the kernel already distributes flows across queues
+						if err := f.frameBatchers[queue].Write(ctx, frame); err != nil {
+							// Clients which haven't been opened before the shutdown return the context's error
+							if errors.Is(err, os.ErrClosed) || errors.Is(err, ctx.Err()) {
+								break
+							}
+
+							log.Println("could not write to frame client, dropping frame and continuing in 250ms", err)
+
+							time.Sleep(time.Millisecond * 250)
+
+							break
+						}
+					}
+				}
+			}
+		}(queue)
+	}
+
+	if genesis {
+		frameReaders.Add(1)
+
+		go func() {
+			defer frameReaders.Done()
+
+			log.Println("Reading from frame service")
+
+			rawFrames := [][]byte{}
+
+			for {
+				frame, err := f.frameService.Read()
+				if err != nil {
+					if errors.Is(err, os.ErrClosed) {
+						return
+					}
+
+					log.Println("could not read from frame service, dropping frame and continuing in 250ms", err)
+
+					time.Sleep(time.Millisecond * 250)
+				}
+
+				if frame == nil {
+					log.Println("read invalid frame from from frame service, dropping frame")
+
+					continue
+				}
+
+				if frame.Peers != nil {
+					if f.endToEndEncryptor != nil && isPeersAnnouncement(frame) {
+						f.endToEndEncryptor.SetPeers(frame.Peers.PublicKeys)
+					}
+
+					continue
+				}
+
+				if f.debug {
+					log.Println("Read frame from from frame service")
+				}
+
+				rawFrames, err = f.frameConverter.ToInternal(rawFrames[:0], frame)
+				if err != nil {
+					log.Println("could not convert external frame to internal frame, dropping frame", err)
+
+					continue
+				}
+
+				if f.debug {
+					log.Println("Writing frame from frame service to network device")
+				}
+
+				for _, rawFrame := range rawFrames {
+					writeToDevice(rawFrame)
+				}
+			}
+		}()
+	} else {
+		for _, frameClient := range f.frameClients {
+			frameReaders.Add(1)
+
+			go func(frameClient *clients.FrameClient) {
+				defer frameReaders.Done()
+
+				log.Println("Reading from frame client")
+
+				rawFrames := [][]byte{}
+
+				for {
+					frame, err := frameClient.Read(ctx)
+					if err != nil {
+						// The stream has been closed by the shutdown, so there is nothing to reconnect
+						if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+							return
+						}
+
+						log.Println("could not read from frame client, dropping frame and reconnecting in 250ms", err)
+
+						time.Sleep(time.Millisecond * 250)
+
+						if err := frameClient.Open(); err != nil {
+							// Reconnecting can't succeed if the hub rejected the client
+							if errors.Is(err, transports.ErrUnauthenticated) || errors.Is(err, transports.ErrPermissionDenied) {
+								fail("could not reconnect to frame client", err)
+
+								return
+							}
+
+							log.Println("could not reconnect to frame client, retrying in 250ms")
+						}
+
+						continue
+					}
+
+					if frame == nil {
+						log.Println("read invalid frame from from frame client, dropping frame")
+
+						continue
+					}
+
+					if frame.Peers != nil {
+						if f.endToEndEncryptor != nil && isPeersAnnouncement(frame) {
+							f.endToEndEncryptor.SetPeers(frame.Peers.PublicKeys)
+						}
+
+						continue
+					}
+
+					rawFrames, err = f.frameConverter.ToInternal(rawFrames[:0], frame)
+					if err != nil {
+						log.Println("could not convert external frame to internal frame, dropping frame", err)
+
+						continue
+					}
+
+					if f.debug {
+						log.Println("Writing frame from frame client to network device")
+					}
+
+					for _, rawFrame := range rawFrames {
+						writeToDevice(rawFrame)
+					}
+				}
+			}(frameClient)
+		}
+	}
+
+	<-ctx.Done()
+
+	log.Println("Shutting down")
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		// Frames which have already been read are sent before the streams are closed; the frame readers exit once they have been received
+		if genesis {
+			log.Println("Closing frame service")
+
+			if err := f.frameService.Close(); err != nil {
+				fail("could not close frame service", err)
+			}
+
+			log.Println("Closing frame server")
+
+			if err := f.frameServer.Close(); err != nil {
+				fail("could not close frame server", err)
+			}
+		} else {
+			log.Println("Closing frame clients")
+
+			for queue, frameClient := range f.frameClients {
+				// Clients which haven't been opened before the shutdown have nothing to flush to
+				if err := f.frameBatchers[queue].Flush(ctx); err != nil && !errors.Is(err, os.ErrClosed) && !errors.Is(err, ctx.Err()) {
+					fail("could not flush frame batcher", err)
+				}
+
+				if err := frameClient.Close(); err != nil {
+					fail("could not close frame client", err)
+				}
+			}
+		}
+
+		// The received frames are written to the network device before it is closed
+		frameReaders.Wait()
+
+		for _, frames := range deviceFrames {
+			close(frames)
+		}
+
+		deviceWriters.Wait()
+
+		// The addresses, routes and bridge membership would otherwise outlive the process if the device is persistent
+		log.Println("Closing network device")
+
+		if err := f.device.Close(); err != nil {
+			fail("could not close network device", err)
+		}
+
+		deviceReaders.Wait()
+	}()
+
+	timer := time.NewTimer(shutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-closed:
+	case <-timer.C:
+		return ErrShutdownTimeout
+	}
+
+	failureLock.Lock()
+	defer failureLock.Unlock()
+
+	return failure
+}
+
+// DroppedReplays returns the number of replayed frames which the frame service or clients have dropped
+func (f *FrameForwarder) DroppedReplays() uint64 {
+	if f.frameService != nil {
+		return f.frameService.DroppedReplays()
+	}
+
+	droppedReplays := uint64(0)
+	for _, frameClient := range f.frameClients {
+		droppedReplays += frameClient.DroppedReplays()
+	}
+
+	return droppedReplays
+}
+
+// isPeersAnnouncement returns whether the frame only announces peers, which only the hub does; frames which carry
+// peers alongside content have been relayed from another peer and are dropped
+func isPeersAnnouncement(frame *proto.FrameMessage) bool {
+	return frame.Peers != nil && len(frame.Content) == 0 && len(frame.Sender) == 0 && len(frame.Recipient) == 0
+}
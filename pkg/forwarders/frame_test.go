@@ -0,0 +1,241 @@
+package forwarders
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/clients"
+	"github.com/pojntfx/gloeth/pkg/converters"
+	"github.com/pojntfx/gloeth/pkg/devices"
+	"github.com/pojntfx/gloeth/pkg/encryptors"
+	"github.com/pojntfx/gloeth/pkg/fixtures"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/transports"
+)
+
+const (
+	testFrameLength = 1514
+)
+
+var (
+	broadcastAddress = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	hubAddress       = []byte{0x02, 0, 0, 0, 0, 0x01}
+	spokeAAddress    = []byte{0x02, 0, 0, 0, 0, 0x0a}
+	spokeBAddress    = []byte{0x02, 0, 0, 0, 0, 0x0b}
+	attackerAddress  = []byte{0x02, 0, 0, 0, 0, 0x0e}
+)
+
+// openTestFrameForwarder opens the forwarder until the test ends
+func openTestFrameForwarder(t *testing.T, frameForwarder *FrameForwarder) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- frameForwarder.Open(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func newTestHub(t *testing.T, transport transports.Transport) *devices.MemoryDevice {
+	t.Helper()
+
+	framePool := pools.NewFramePool(testFrameLength)
+	frameConverter := converters.NewFrameConverter(nil, framePool, false, proto.PayloadType_ETHERNET)
+	frameService := fixtures.NewFrameService(t, frameConverter, transport.MaximumBatchLength(), nil)
+
+	device := devices.NewMemoryDevice(1, framePool)
+	openTestFrameForwarder(t, NewHubFrameForwarder(device, transport, frameService, frameConverter, nil, false, proto.PayloadType_ETHERNET, false))
+
+	return device
+}
+
+// newTestSpoke opens a spoke; endToEndEncryptor is optional
+func newTestSpoke(t *testing.T, transport transports.Transport, peerID string, endToEndEncryptor *encryptors.EndToEndEncryptor) *devices.MemoryDevice {
+	t.Helper()
+
+	var publicKey []byte
+	if endToEndEncryptor != nil {
+		publicKey = endToEndEncryptor.PublicKey()
+	}
+
+	framePool := pools.NewFramePool(testFrameLength)
+	frameConverter := converters.NewFrameConverter(endToEndEncryptor, framePool, false, proto.PayloadType_ETHERNET)
+
+	device := devices.NewMemoryDevice(1, framePool)
+	openTestFrameForwarder(t, NewSpokeFrameForwarder(device, transport, frameConverter, endToEndEncryptor, framePool, SpokeFrameForwarderConfig{
+		PreSharedKey:       fixtures.PreSharedKey,
+		PublicKey:          publicKey,
+		PeerID:             []byte(peerID),
+		PayloadType:        proto.PayloadType_ETHERNET,
+		BatchMaximumFrames: 32,
+		BatchMaximumDelay:  time.Microsecond * 200,
+	}))
+
+	return device
+}
+
+// broadcastUntilReceived sends broadcasts from the source until the destination has received one of them, as
+// broadcasts are only received once the destination has connected and, with end-to-end encryption, has been announced
+func broadcastUntilReceived(t *testing.T, source *devices.MemoryDevice, sourceAddress []byte, destination *devices.MemoryDevice) []byte {
+	t.Helper()
+
+	for attempt := byte(0); attempt < 50; attempt++ {
+		broadcast := fixtures.NewEthernetFrame(broadcastAddress, sourceAddress, attempt)
+		if err := source.Inject(0, broadcast); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := capture(destination, broadcast, time.Millisecond*100); err == nil {
+			return broadcast
+		}
+	}
+
+	t.Fatal("expected a broadcast to be received")
+
+	return nil
+}
+
+// capture waits for the frame to be written to the device, skipping all others
+func capture(device *devices.MemoryDevice, rawFrame []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		captured, err := device.Capture(ctx, 0)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(captured, rawFrame) {
+			return nil
+		}
+	}
+}
+
+func TestFrameForwardersConnectHubAndSpokes(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+
+	hub := newTestHub(t, transport)
+	spokeA := newTestSpoke(t, transport, "spoke-a", nil)
+	spokeB := newTestSpoke(t, transport, "spoke-b", nil)
+
+	broadcast := broadcastUntilReceived(t, spokeA, spokeAAddress, spokeB)
+
+	if err := capture(hub, broadcast, time.Second*5); err != nil {
+		t.Fatalf("expected the broadcast to reach the hub: %v", err)
+	}
+
+	// The hub has learned spoke A's address from the broadcast
+	unicast := fixtures.NewEthernetFrame(spokeAAddress, spokeBAddress, 1, 2, 3)
+	if err := spokeB.Inject(0, unicast); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := capture(spokeA, unicast, time.Second*5); err != nil {
+		t.Fatalf("expected the unicast to reach spoke A: %v", err)
+	}
+
+	if err := capture(hub, unicast, time.Millisecond*100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the unicast not to reach the hub, got %v", err)
+	}
+
+	// The hub has learned spoke B's address from the unicast
+	reply := fixtures.NewEthernetFrame(spokeBAddress, hubAddress, 4, 5, 6)
+	if err := hub.Inject(0, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := capture(spokeB, reply, time.Second*5); err != nil {
+		t.Fatalf("expected the reply to reach spoke B: %v", err)
+	}
+
+	if err := capture(spokeA, reply, time.Millisecond*100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the reply not to reach spoke A, got %v", err)
+	}
+}
+
+func TestFrameForwardersIgnorePeersWhichSpokesSend(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+
+	privateKeys := []*ecdh.PrivateKey{}
+	allowedPeers := [][]byte{}
+	for i := 0; i < 2; i++ {
+		privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		privateKeys = append(privateKeys, privateKey)
+		allowedPeers = append(allowedPeers, privateKey.PublicKey().Bytes())
+	}
+
+	hub := newTestHub(t, transport)
+	spokeA := newTestSpoke(t, transport, "spoke-a", encryptors.NewEndToEndEncryptor(privateKeys[0], proto.PayloadType_ETHERNET, allowedPeers))
+	spokeB := newTestSpoke(t, transport, "spoke-b", encryptors.NewEndToEndEncryptor(privateKeys[1], proto.PayloadType_ETHERNET, allowedPeers))
+
+	// Sealed frames are only received once both spokes have been announced to each other
+	broadcastUntilReceived(t, spokeA, spokeAAddress, spokeB)
+	broadcastUntilReceived(t, spokeB, spokeBAddress, spokeA)
+
+	attacker := clients.NewFrameClient(transport, fixtures.PreSharedKey, nil, []byte("attacker"), nil, false, proto.PayloadType_ETHERNET)
+	if err := attacker.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer attacker.Close()
+
+	// Announcing peers which aren't allowed would leave the spokes without any peers to seal frames for
+	peers := &proto.PeersMessage{PublicKeys: [][]byte{[]byte("evil")}}
+	if err := attacker.Write(context.Background(), &proto.FrameMessage{
+		Batch: &proto.BatchMessage{Frames: []*proto.FrameMessage{
+			{Peers: peers, PayloadType: proto.PayloadType_ETHERNET},
+			{Content: fixtures.NewEthernetFrame(broadcastAddress, attackerAddress, 1), Peers: peers, PayloadType: proto.PayloadType_ETHERNET},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames are forwarded in order, so the attack has been forwarded to the spokes once the hub has received the next frame
+	marker := fixtures.NewEthernetFrame(broadcastAddress, attackerAddress, 2)
+	if err := attacker.Write(context.Background(), &proto.FrameMessage{Content: marker, PayloadType: proto.PayloadType_ETHERNET}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := capture(hub, marker, time.Second*5); err != nil {
+		t.Fatalf("expected the marker to reach the hub: %v", err)
+	}
+
+	for i := byte(0); i < 10; i++ {
+		for _, direction := range []struct {
+			source        *devices.MemoryDevice
+			sourceAddress []byte
+			destination   *devices.MemoryDevice
+		}{
+			{spokeA, spokeAAddress, spokeB},
+			{spokeB, spokeBAddress, spokeA},
+		} {
+			broadcast := fixtures.NewEthernetFrame(broadcastAddress, direction.sourceAddress, 0xff, i)
+			if err := direction.source.Inject(0, broadcast); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := capture(direction.destination, broadcast, time.Second*5); err != nil {
+				t.Fatalf("expected the spokes to keep their peers: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package hashers
+
+import (
+	"encoding/binary"
+)
+
+const (
+	ethernetHeaderLength = 14
+	etherTypeIPv4        = 0x0800
+	etherTypeIPv6        = 0x86dd
+	protocolTCP          = 6
+	protocolUDP          = 17
+
+	// FNV-1a
+	offsetBasis = 2166136261
+	prime       = 16777619
+)
+
+// HashFlow hashes the addresses and ports of a frame, so that all frames of a flow are mapped to the same
+// queue or stream and don't get reordered; it falls back to the layers which are present, down to the
+// hardware addresses, so only passing the Ethernet header yields a consistent per-host-pair hash
+func HashFlow(rawFrame []byte) uint32 {
+	if len(rawFrame) < ethernetHeaderLength {
+		return HashBytes(rawFrame)
+	}
+
+	hash := hashBytes(offsetBasis, rawFrame[:ethernetHeaderLength])
+
+	switch binary.BigEndian.Uint16(rawFrame[12:14]) {
+	case etherTypeIPv4:
+		return hashIPv4(hash, rawFrame[ethernetHeaderLength:])
+	case etherTypeIPv6:
+		return hashIPv6(hash, rawFrame[ethernetHeaderLength:])
+	default:
+		return hash
+	}
+}
+
+// HashPacket hashes the addresses and ports of an IP packet like HashFlow does for frames
+func HashPacket(packet []byte) uint32 {
+	if len(packet) == 0 {
+		return offsetBasis
+	}
+
+	switch packet[0] >> 4 {
+	case 4:
+		return hashIPv4(offsetBasis, packet)
+	case 6:
+		return hashIPv6(offsetBasis, packet)
+	default:
+		return HashBytes(packet)
+	}
+}
+
+func hashIPv4(hash uint32, packet []byte) uint32 {
+	if len(packet) < 20 {
+		return hash
+	}
+
+	headerLength := int(packet[0]&0x0f) * 4
+
+	// The header checksum between the protocol and the addresses changes with every packet's identification and TTL
+	hash = hashBytes(hash, packet[9:10])
+	hash = hashBytes(hash, packet[12:20])
+
+	// Only the first fragment contains the ports
+	if fragmentOffset := binary.BigEndian.Uint16(packet[6:8]) & 0x1fff; fragmentOffset != 0 || len(packet) < headerLength {
+		return hash
+	}
+
+	return hashTransport(hash, packet[9], packet[headerLength:])
+}
+
+func hashIPv6(hash uint32, packet []byte) uint32 {
+	if len(packet) < 40 {
+		return hash
+	}
+
+	hash = hashBytes(hash, packet[6:7])
+	hash = hashBytes(hash, packet[8:40])
+
+	return hashTransport(hash, packet[6], packet[40:])
+}
+
+func hashTransport(hash uint32, protocol byte, transport []byte) uint32 {
+	if (protocol == protocolTCP || protocol == protocolUDP) && len(transport) >= 4 {
+		hash = hashBytes(hash, transport[:4])
+	}
+
+	return hash
+}
+
+// HashBytes hashes opaque data, i.e. the public key of an end-to-end encrypted frame's sender
+func HashBytes(data []byte) uint32 {
+	return hashBytes(offsetBasis, data)
+}
+
+func hashBytes(hash uint32, data []byte) uint32 {
+	for _, b := range data {
+		hash ^= uint32(b)
+		hash *= prime
+	}
+
+	return hash
+}
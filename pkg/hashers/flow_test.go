@@ -0,0 +1,59 @@
+package hashers
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// newTCPv4Packet returns an IPv4 packet with a valid header checksum and a TCP header with the given ports
+func newTCPv4Packet(identification uint16, ttl byte, sourcePort uint16, destinationPort uint16) []byte {
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[4:6], identification)
+	packet[8] = ttl
+	packet[9] = protocolTCP
+	copy(packet[12:16], []byte{10, 0, 0, 1})
+	copy(packet[16:20], []byte{10, 0, 0, 2})
+
+	sum := uint32(0)
+	for i := 0; i < 20; i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(packet[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	binary.BigEndian.PutUint16(packet[10:12], ^uint16(sum))
+
+	binary.BigEndian.PutUint16(packet[20:22], sourcePort)
+	binary.BigEndian.PutUint16(packet[22:24], destinationPort)
+
+	return packet
+}
+
+func newTCPv4Frame(packet []byte) []byte {
+	header := []byte{0x02, 0, 0, 0, 0, 0x02, 0x02, 0, 0, 0, 0, 0x01, 0x08, 0x00}
+
+	return append(header, packet...)
+}
+
+func TestHashPacketIgnoresPerPacketFields(t *testing.T) {
+	first := newTCPv4Packet(1, 64, 40000, 443)
+	second := newTCPv4Packet(2, 63, 40000, 443)
+
+	if binary.BigEndian.Uint16(first[10:12]) == binary.BigEndian.Uint16(second[10:12]) {
+		t.Fatal("expected the packets to have different header checksums")
+	}
+
+	if HashPacket(first) != HashPacket(second) {
+		t.Fatal("expected packets of the same flow to have the same hash")
+	}
+
+	if HashFlow(newTCPv4Frame(first)) != HashFlow(newTCPv4Frame(second)) {
+		t.Fatal("expected frames of the same flow to have the same hash")
+	}
+
+	if HashPacket(first) == HashPacket(newTCPv4Packet(1, 64, 40001, 443)) {
+		t.Fatal("expected packets of different flows to have different hashes")
+	}
+}
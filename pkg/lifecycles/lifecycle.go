@@ -0,0 +1,71 @@
+package lifecycles
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// Lifecycle tracks whether a component has been opened or closed, so that callers can block until it is usable instead of polling it
+type Lifecycle struct {
+	opened    chan struct{}
+	closed    chan struct{}
+	openOnce  sync.Once
+	closeOnce sync.Once
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		opened: make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+// Open marks the component as usable and wakes up all waiters; it can be called again, i.e. after a reconnect
+func (l *Lifecycle) Open() {
+	l.openOnce.Do(func() {
+		close(l.opened)
+	})
+}
+
+// Close marks the component as closed and wakes up all waiters; it returns false if it had already been closed
+func (l *Lifecycle) Close() bool {
+	closed := false
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		closed = true
+	})
+
+	return closed
+}
+
+// Closed returns a channel which is closed once the component has been closed
+func (l *Lifecycle) Closed() <-chan struct{} {
+	return l.closed
+}
+
+// Wait blocks until the component has been opened; it returns os.ErrClosed if the component is closed and ctx.Err() if ctx is done first.
+// Components which are open are usable even with a done ctx, i.e. to flush them during a shutdown.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	select {
+	case <-l.closed:
+		return os.ErrClosed
+	default:
+	}
+
+	select {
+	case <-l.opened:
+		return nil
+	default:
+	}
+
+	select {
+	case <-l.opened:
+		return nil
+	case <-l.closed:
+		return os.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package lifecycles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLifecycleWakesUpWaitersOnceOpened(t *testing.T) {
+	lifecycle := NewLifecycle()
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- lifecycle.Wait(context.Background())
+		}()
+	}
+
+	// Reconnects open the lifecycle again
+	lifecycle.Open()
+	lifecycle.Open()
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLifecycleWakesUpWaitersOnceClosed(t *testing.T) {
+	lifecycle := NewLifecycle()
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- lifecycle.Wait(context.Background())
+		}()
+	}
+
+	closed := int32(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if lifecycle.Close() {
+				atomic.AddInt32(&closed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if closed != 1 {
+		t.Fatalf("expected Close to report closing the lifecycle once, got %v", closed)
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; !errors.Is(err, os.ErrClosed) {
+			t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+		}
+	}
+
+	// Lifecycles which have been closed stay closed, even if they are opened afterwards
+	lifecycle.Open()
+
+	if err := lifecycle.Wait(context.Background()); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestLifecycleWaitsUntilContextIsDone(t *testing.T) {
+	lifecycle := NewLifecycle()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := lifecycle.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	// Open lifecycles are usable with a done context, i.e. during a shutdown
+	lifecycle.Open()
+
+	for i := 0; i < 100; i++ {
+		if err := lifecycle.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package parsers
+
+import (
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+const (
+	ethernetHeaderLength = 14
+	ipv4HeaderLength     = 20
+	ipv6HeaderLength     = 40
+)
+
+// Addresses returns the destination and source address of a frame, which are hardware addresses for
+// Ethernet frames and IP addresses for IP packets; ok is false if the frame is too short to contain them
+func Addresses(payloadType proto.PayloadType, rawFrame []byte) (destination []byte, source []byte, ok bool) {
+	if payloadType == proto.PayloadType_ETHERNET {
+		if len(rawFrame) < ethernetHeaderLength {
+			return nil, nil, false
+		}
+
+		return rawFrame[0:6], rawFrame[6:12], true
+	}
+
+	if len(rawFrame) == 0 {
+		return nil, nil, false
+	}
+
+	switch rawFrame[0] >> 4 {
+	case 4:
+		if len(rawFrame) < ipv4HeaderLength {
+			return nil, nil, false
+		}
+
+		return rawFrame[16:20], rawFrame[12:16], true
+	case 6:
+		if len(rawFrame) < ipv6HeaderLength {
+			return nil, nil, false
+		}
+
+		return rawFrame[24:40], rawFrame[8:24], true
+	default:
+		return nil, nil, false
+	}
+}
+
+// IsUnicast returns false for broadcast, multicast and unspecified addresses, which can't be learned or looked up
+func IsUnicast(payloadType proto.PayloadType, address []byte) bool {
+	if payloadType == proto.PayloadType_ETHERNET {
+		return address[0]&0x01 == 0
+	}
+
+	if len(address) == 4 {
+		return address[0] < 224 && !isZero(address)
+	}
+
+	return address[0] != 0xff && !isZero(address)
+}
+
+func isZero(address []byte) bool {
+	for _, b := range address {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
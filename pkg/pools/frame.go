@@ -0,0 +1,77 @@
+package pools
+
+import (
+	"sync"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+// FramePool recycles frame buffers and messages so that the hot path doesn't allocate for every frame
+type FramePool struct {
+	bufferSize int
+	buffers    sync.Pool
+	headers    sync.Pool
+	messages   sync.Pool
+}
+
+func NewFramePool(bufferSize int) *FramePool {
+	pool := &FramePool{bufferSize: bufferSize}
+
+	pool.buffers.New = func() interface{} {
+		buffer := make([]byte, bufferSize)
+
+		return &buffer
+	}
+
+	pool.headers.New = func() interface{} {
+		return new([]byte)
+	}
+
+	pool.messages.New = func() interface{} {
+		return &proto.FrameMessage{}
+	}
+
+	return pool
+}
+
+func (p *FramePool) GetBuffer() []byte {
+	header := p.buffers.Get().(*[]byte)
+	buffer := *header
+
+	// Slice headers are recycled too, as boxing a new one on every put would allocate
+	*header = nil
+	p.headers.Put(header)
+
+	return buffer
+}
+
+// PutBuffer recycles a buffer; buffers which are too small, i.e. because they weren't taken from the pool, are left to the garbage collector
+func (p *FramePool) PutBuffer(buffer []byte) {
+	if cap(buffer) < p.bufferSize {
+		return
+	}
+
+	header := p.headers.Get().(*[]byte)
+	*header = buffer[:p.bufferSize]
+
+	p.buffers.Put(header)
+}
+
+func (p *FramePool) GetMessage() *proto.FrameMessage {
+	return p.messages.Get().(*proto.FrameMessage)
+}
+
+// PutMessage recycles a message, including its content and batched frames; the caller must not retain any of them
+func (p *FramePool) PutMessage(frame *proto.FrameMessage) {
+	if frame.Batch != nil {
+		for _, batchedFrame := range frame.Batch.Frames {
+			p.PutMessage(batchedFrame)
+		}
+	}
+
+	p.PutBuffer(frame.Content)
+
+	frame.Reset()
+
+	p.messages.Put(frame)
+}
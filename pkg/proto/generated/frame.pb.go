@@ -29,13 +29,122 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type Compression int32
+
+const (
+	Compression_NONE   Compression = 0
+	Compression_ZSTD   Compression = 1
+	Compression_LZ4    Compression = 2
+	Compression_SNAPPY Compression = 3
+)
+
+// Enum value maps for Compression.
+var (
+	Compression_name = map[int32]string{
+		0: "NONE",
+		1: "ZSTD",
+		2: "LZ4",
+		3: "SNAPPY",
+	}
+	Compression_value = map[string]int32{
+		"NONE":   0,
+		"ZSTD":   1,
+		"LZ4":    2,
+		"SNAPPY": 3,
+	}
+)
+
+func (x Compression) Enum() *Compression {
+	p := new(Compression)
+	*p = x
+	return p
+}
+
+func (x Compression) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Compression) Descriptor() protoreflect.EnumDescriptor {
+	return file_frame_proto_enumTypes[0].Descriptor()
+}
+
+func (Compression) Type() protoreflect.EnumType {
+	return &file_frame_proto_enumTypes[0]
+}
+
+func (x Compression) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Compression.Descriptor instead.
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{0}
+}
+
+type PayloadType int32
+
+const (
+	PayloadType_ETHERNET PayloadType = 0
+	PayloadType_IP       PayloadType = 1
+)
+
+// Enum value maps for PayloadType.
+var (
+	PayloadType_name = map[int32]string{
+		0: "ETHERNET",
+		1: "IP",
+	}
+	PayloadType_value = map[string]int32{
+		"ETHERNET": 0,
+		"IP":       1,
+	}
+)
+
+func (x PayloadType) Enum() *PayloadType {
+	p := new(PayloadType)
+	*p = x
+	return p
+}
+
+func (x PayloadType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PayloadType) Descriptor() protoreflect.EnumDescriptor {
+	return file_frame_proto_enumTypes[1].Descriptor()
+}
+
+func (PayloadType) Type() protoreflect.EnumType {
+	return &file_frame_proto_enumTypes[1]
+}
+
+func (x PayloadType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PayloadType.Descriptor instead.
+func (PayloadType) EnumDescriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{1}
+}
+
 type FrameMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Content      []byte `protobuf:"bytes,1,opt,name=Content,proto3" json:"Content,omitempty"`
-	PreSharedKey string `protobuf:"bytes,2,opt,name=PreSharedKey,proto3" json:"PreSharedKey,omitempty"`
+	Content         []byte          `protobuf:"bytes,1,opt,name=Content,proto3" json:"Content,omitempty"`
+	Hello           *HelloMessage   `protobuf:"bytes,3,opt,name=Hello,proto3" json:"Hello,omitempty"`
+	Welcome         *WelcomeMessage `protobuf:"bytes,4,opt,name=Welcome,proto3" json:"Welcome,omitempty"`
+	Sender          []byte          `protobuf:"bytes,5,opt,name=Sender,proto3" json:"Sender,omitempty"`
+	Recipient       []byte          `protobuf:"bytes,6,opt,name=Recipient,proto3" json:"Recipient,omitempty"`
+	Peers           *PeersMessage   `protobuf:"bytes,7,opt,name=Peers,proto3" json:"Peers,omitempty"`
+	Sequence        uint64          `protobuf:"varint,8,opt,name=Sequence,proto3" json:"Sequence,omitempty"`
+	Batch           *BatchMessage   `protobuf:"bytes,9,opt,name=Batch,proto3" json:"Batch,omitempty"`
+	Compression     Compression     `protobuf:"varint,10,opt,name=Compression,proto3,enum=com.pojtinger.felicitas.gloeth.Compression" json:"Compression,omitempty"`
+	Offload         *OffloadMessage `protobuf:"bytes,11,opt,name=Offload,proto3" json:"Offload,omitempty"`
+	PayloadType     PayloadType     `protobuf:"varint,12,opt,name=PayloadType,proto3,enum=com.pojtinger.felicitas.gloeth.PayloadType" json:"PayloadType,omitempty"`
+	ControlSequence uint64          `protobuf:"varint,13,opt,name=ControlSequence,proto3" json:"ControlSequence,omitempty"`
+	Acknowledgement uint64          `protobuf:"varint,14,opt,name=Acknowledgement,proto3" json:"Acknowledgement,omitempty"`
 }
 
 func (x *FrameMessage) Reset() {
@@ -77,35 +186,530 @@ func (x *FrameMessage) GetContent() []byte {
 	return nil
 }
 
-func (x *FrameMessage) GetPreSharedKey() string {
+func (x *FrameMessage) GetHello() *HelloMessage {
+	if x != nil {
+		return x.Hello
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetWelcome() *WelcomeMessage {
+	if x != nil {
+		return x.Welcome
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetSender() []byte {
+	if x != nil {
+		return x.Sender
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetRecipient() []byte {
+	if x != nil {
+		return x.Recipient
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetPeers() *PeersMessage {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *FrameMessage) GetBatch() *BatchMessage {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetCompression() Compression {
+	if x != nil {
+		return x.Compression
+	}
+	return Compression_NONE
+}
+
+func (x *FrameMessage) GetOffload() *OffloadMessage {
+	if x != nil {
+		return x.Offload
+	}
+	return nil
+}
+
+func (x *FrameMessage) GetPayloadType() PayloadType {
+	if x != nil {
+		return x.PayloadType
+	}
+	return PayloadType_ETHERNET
+}
+
+func (x *FrameMessage) GetControlSequence() uint64 {
+	if x != nil {
+		return x.ControlSequence
+	}
+	return 0
+}
+
+func (x *FrameMessage) GetAcknowledgement() uint64 {
+	if x != nil {
+		return x.Acknowledgement
+	}
+	return 0
+}
+
+type HelloMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PreSharedKey string        `protobuf:"bytes,1,opt,name=PreSharedKey,proto3" json:"PreSharedKey,omitempty"`
+	PublicKey    []byte        `protobuf:"bytes,2,opt,name=PublicKey,proto3" json:"PublicKey,omitempty"`
+	Compressions []Compression `protobuf:"varint,3,rep,packed,name=Compressions,proto3,enum=com.pojtinger.felicitas.gloeth.Compression" json:"Compressions,omitempty"`
+	PeerID       []byte        `protobuf:"bytes,4,opt,name=PeerID,proto3" json:"PeerID,omitempty"`
+	Offload      bool          `protobuf:"varint,5,opt,name=Offload,proto3" json:"Offload,omitempty"`
+	PayloadType  PayloadType   `protobuf:"varint,6,opt,name=PayloadType,proto3,enum=com.pojtinger.felicitas.gloeth.PayloadType" json:"PayloadType,omitempty"`
+}
+
+func (x *HelloMessage) Reset() {
+	*x = HelloMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frame_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HelloMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HelloMessage) ProtoMessage() {}
+
+func (x *HelloMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HelloMessage.ProtoReflect.Descriptor instead.
+func (*HelloMessage) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HelloMessage) GetPreSharedKey() string {
 	if x != nil {
 		return x.PreSharedKey
 	}
 	return ""
 }
 
+func (x *HelloMessage) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *HelloMessage) GetCompressions() []Compression {
+	if x != nil {
+		return x.Compressions
+	}
+	return nil
+}
+
+func (x *HelloMessage) GetPeerID() []byte {
+	if x != nil {
+		return x.PeerID
+	}
+	return nil
+}
+
+func (x *HelloMessage) GetOffload() bool {
+	if x != nil {
+		return x.Offload
+	}
+	return false
+}
+
+func (x *HelloMessage) GetPayloadType() PayloadType {
+	if x != nil {
+		return x.PayloadType
+	}
+	return PayloadType_ETHERNET
+}
+
+type WelcomeMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Compression Compression `protobuf:"varint,1,opt,name=Compression,proto3,enum=com.pojtinger.felicitas.gloeth.Compression" json:"Compression,omitempty"`
+	Offload     bool        `protobuf:"varint,2,opt,name=Offload,proto3" json:"Offload,omitempty"`
+}
+
+func (x *WelcomeMessage) Reset() {
+	*x = WelcomeMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frame_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WelcomeMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WelcomeMessage) ProtoMessage() {}
+
+func (x *WelcomeMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WelcomeMessage.ProtoReflect.Descriptor instead.
+func (*WelcomeMessage) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WelcomeMessage) GetCompression() Compression {
+	if x != nil {
+		return x.Compression
+	}
+	return Compression_NONE
+}
+
+func (x *WelcomeMessage) GetOffload() bool {
+	if x != nil {
+		return x.Offload
+	}
+	return false
+}
+
+type PeersMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PublicKeys [][]byte `protobuf:"bytes,1,rep,name=PublicKeys,proto3" json:"PublicKeys,omitempty"`
+}
+
+func (x *PeersMessage) Reset() {
+	*x = PeersMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frame_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeersMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeersMessage) ProtoMessage() {}
+
+func (x *PeersMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeersMessage.ProtoReflect.Descriptor instead.
+func (*PeersMessage) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PeersMessage) GetPublicKeys() [][]byte {
+	if x != nil {
+		return x.PublicKeys
+	}
+	return nil
+}
+
+type BatchMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Frames []*FrameMessage `protobuf:"bytes,1,rep,name=Frames,proto3" json:"Frames,omitempty"`
+}
+
+func (x *BatchMessage) Reset() {
+	*x = BatchMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frame_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchMessage) ProtoMessage() {}
+
+func (x *BatchMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchMessage.ProtoReflect.Descriptor instead.
+func (*BatchMessage) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchMessage) GetFrames() []*FrameMessage {
+	if x != nil {
+		return x.Frames
+	}
+	return nil
+}
+
+type OffloadMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Flags          uint32 `protobuf:"varint,1,opt,name=Flags,proto3" json:"Flags,omitempty"`
+	GSOType        uint32 `protobuf:"varint,2,opt,name=GSOType,proto3" json:"GSOType,omitempty"`
+	HeaderLength   uint32 `protobuf:"varint,3,opt,name=HeaderLength,proto3" json:"HeaderLength,omitempty"`
+	SegmentSize    uint32 `protobuf:"varint,4,opt,name=SegmentSize,proto3" json:"SegmentSize,omitempty"`
+	ChecksumStart  uint32 `protobuf:"varint,5,opt,name=ChecksumStart,proto3" json:"ChecksumStart,omitempty"`
+	ChecksumOffset uint32 `protobuf:"varint,6,opt,name=ChecksumOffset,proto3" json:"ChecksumOffset,omitempty"`
+}
+
+func (x *OffloadMessage) Reset() {
+	*x = OffloadMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frame_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OffloadMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OffloadMessage) ProtoMessage() {}
+
+func (x *OffloadMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OffloadMessage.ProtoReflect.Descriptor instead.
+func (*OffloadMessage) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *OffloadMessage) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *OffloadMessage) GetGSOType() uint32 {
+	if x != nil {
+		return x.GSOType
+	}
+	return 0
+}
+
+func (x *OffloadMessage) GetHeaderLength() uint32 {
+	if x != nil {
+		return x.HeaderLength
+	}
+	return 0
+}
+
+func (x *OffloadMessage) GetSegmentSize() uint32 {
+	if x != nil {
+		return x.SegmentSize
+	}
+	return 0
+}
+
+func (x *OffloadMessage) GetChecksumStart() uint32 {
+	if x != nil {
+		return x.ChecksumStart
+	}
+	return 0
+}
+
+func (x *OffloadMessage) GetChecksumOffset() uint32 {
+	if x != nil {
+		return x.ChecksumOffset
+	}
+	return 0
+}
+
 var File_frame_proto protoreflect.FileDescriptor
 
 var file_frame_proto_rawDesc = []byte{
-	0x0a, 0x0b, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1a, 0x63,
+	0x0a, 0x0b, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1e, 0x63,
 	0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c,
-	0x69, 0x78, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x22, 0x4c, 0x0a, 0x0c, 0x46, 0x72, 0x61,
-	0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x43, 0x6f, 0x6e,
-	0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x43, 0x6f, 0x6e, 0x74,
-	0x65, 0x6e, 0x74, 0x12, 0x22, 0x0a, 0x0c, 0x50, 0x72, 0x65, 0x53, 0x68, 0x61, 0x72, 0x65, 0x64,
-	0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x50, 0x72, 0x65, 0x53, 0x68,
-	0x61, 0x72, 0x65, 0x64, 0x4b, 0x65, 0x79, 0x32, 0x7a, 0x0a, 0x0c, 0x46, 0x72, 0x61, 0x6d, 0x65,
-	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x6a, 0x0a, 0x10, 0x54, 0x72, 0x61, 0x6e, 0x73,
-	0x63, 0x65, 0x69, 0x76, 0x65, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x12, 0x28, 0x2e, 0x63, 0x6f,
+	0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x22, 0xe0, 0x05,
+	0x0a, 0x0c, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x07, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x42, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c,
+	0x6f, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f,
+	0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61,
+	0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x48, 0x0a, 0x07,
+	0x57, 0x65, 0x6c, 0x63, 0x6f, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2e, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65,
+	0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x57,
+	0x65, 0x6c, 0x63, 0x6f, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x07, 0x57,
+	0x65, 0x6c, 0x63, 0x6f, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x1c,
+	0x0a, 0x09, 0x52, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x52, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x42, 0x0a, 0x05,
+	0x50, 0x65, 0x65, 0x72, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69,
+	0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x50, 0x65, 0x65,
+	0x72, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x50, 0x65, 0x65, 0x72, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x08, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x42, 0x0a, 0x05,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x63, 0x6f,
 	0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69,
-	0x78, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x28, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74,
-	0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69, 0x78, 0x2e, 0x67, 0x6c, 0x6f, 0x65,
-	0x74, 0x68, 0x2e, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x28,
-	0x01, 0x30, 0x01, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x70, 0x6f, 0x6a, 0x6e, 0x74, 0x66, 0x78, 0x2f, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68,
-	0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x42, 0x61, 0x74,
+	0x63, 0x68, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x42, 0x61, 0x74, 0x63, 0x68,
+	0x12, 0x4d, 0x0a, 0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74,
+	0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e,
+	0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x52, 0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x48, 0x0a, 0x07, 0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x2e, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72,
+	0x2e, 0x66, 0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74,
+	0x68, 0x2e, 0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x52, 0x07, 0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x4d, 0x0a, 0x0b, 0x50, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x54, 0x79, 0x70, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66,
+	0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e,
+	0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x50, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x54, 0x79, 0x70, 0x65, 0x12, 0x28, 0x0a, 0x0f, 0x43, 0x6f, 0x6e, 0x74,
+	0x72, 0x6f, 0x6c, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x12, 0x28, 0x0a, 0x0f, 0x41, 0x63, 0x6b, 0x6e, 0x6f, 0x77, 0x6c, 0x65, 0x64, 0x67,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x41, 0x63, 0x6b,
+	0x6e, 0x6f, 0x77, 0x6c, 0x65, 0x64, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x4a, 0x04, 0x08, 0x02,
+	0x10, 0x03, 0x52, 0x0c, 0x50, 0x72, 0x65, 0x53, 0x68, 0x61, 0x72, 0x65, 0x64, 0x4b, 0x65, 0x79,
+	0x22, 0xa2, 0x02, 0x0a, 0x0c, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x22, 0x0a, 0x0c, 0x50, 0x72, 0x65, 0x53, 0x68, 0x61, 0x72, 0x65, 0x64, 0x4b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x50, 0x72, 0x65, 0x53, 0x68, 0x61, 0x72,
+	0x65, 0x64, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b,
+	0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63,
+	0x4b, 0x65, 0x79, 0x12, 0x4f, 0x0a, 0x0c, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
+	0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69, 0x63, 0x69,
+	0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0c, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x50, 0x65, 0x65, 0x72, 0x49, 0x44, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x50, 0x65, 0x65, 0x72, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07,
+	0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x4f,
+	0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x4d, 0x0a, 0x0b, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x54, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c, 0x69,
+	0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x50, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x54, 0x79, 0x70, 0x65, 0x22, 0x79, 0x0a, 0x0e, 0x57, 0x65, 0x6c, 0x63, 0x6f, 0x6d, 0x65,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x4d, 0x0a, 0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65, 0x6c,
+	0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x43, 0x6f,
+	0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x4f, 0x66, 0x66, 0x6c, 0x6f, 0x61, 0x64,
+	0x22, 0x2e, 0x0a, 0x0c, 0x50, 0x65, 0x65, 0x72, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x1e, 0x0a, 0x0a, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0c, 0x52, 0x0a, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x73,
+	0x22, 0x54, 0x0a, 0x0c, 0x42, 0x61, 0x74, 0x63, 0x68, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x44, 0x0a, 0x06, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x2c, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72,
+	0x2e, 0x66, 0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74,
+	0x68, 0x2e, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x06,
+	0x46, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x22, 0xd4, 0x01, 0x0a, 0x0e, 0x4f, 0x66, 0x66, 0x6c, 0x6f,
+	0x61, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x46, 0x6c, 0x61,
+	0x67, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x46, 0x6c, 0x61, 0x67, 0x73, 0x12,
+	0x18, 0x0a, 0x07, 0x47, 0x53, 0x4f, 0x54, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x07, 0x47, 0x53, 0x4f, 0x54, 0x79, 0x70, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x20, 0x0a,
+	0x0b, 0x53, 0x65, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0b, 0x53, 0x65, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x12,
+	0x24, 0x0a, 0x0d, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x53, 0x74, 0x61, 0x72, 0x74,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d,
+	0x53, 0x74, 0x61, 0x72, 0x74, 0x12, 0x26, 0x0a, 0x0e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75,
+	0x6d, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x43,
+	0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x2a, 0x36, 0x0a,
+	0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x08, 0x0a, 0x04,
+	0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x5a, 0x53, 0x54, 0x44, 0x10, 0x01,
+	0x12, 0x07, 0x0a, 0x03, 0x4c, 0x5a, 0x34, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x4e, 0x41,
+	0x50, 0x50, 0x59, 0x10, 0x03, 0x2a, 0x23, 0x0a, 0x0b, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x0c, 0x0a, 0x08, 0x45, 0x54, 0x48, 0x45, 0x52, 0x4e, 0x45, 0x54,
+	0x10, 0x00, 0x12, 0x06, 0x0a, 0x02, 0x49, 0x50, 0x10, 0x01, 0x32, 0x82, 0x01, 0x0a, 0x0c, 0x46,
+	0x72, 0x61, 0x6d, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x72, 0x0a, 0x10, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x63, 0x65, 0x69, 0x76, 0x65, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x12,
+	0x2c, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e,
+	0x66, 0x65, 0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68,
+	0x2e, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x2c, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6f, 0x6a, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2e, 0x66, 0x65,
+	0x6c, 0x69, 0x63, 0x69, 0x74, 0x61, 0x73, 0x2e, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2e, 0x46,
+	0x72, 0x61, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42,
+	0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x70, 0x6f,
+	0x6a, 0x6e, 0x74, 0x66, 0x78, 0x2f, 0x67, 0x6c, 0x6f, 0x65, 0x74, 0x68, 0x2f, 0x70, 0x6b, 0x67,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -120,18 +724,37 @@ func file_frame_proto_rawDescGZIP() []byte {
 	return file_frame_proto_rawDescData
 }
 
-var file_frame_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_frame_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_frame_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_frame_proto_goTypes = []interface{}{
-	(*FrameMessage)(nil), // 0: com.pojtinger.felicitas.gloeth.FrameMessage
+	(Compression)(0),       // 0: com.pojtinger.felicitas.gloeth.Compression
+	(PayloadType)(0),       // 1: com.pojtinger.felicitas.gloeth.PayloadType
+	(*FrameMessage)(nil),   // 2: com.pojtinger.felicitas.gloeth.FrameMessage
+	(*HelloMessage)(nil),   // 3: com.pojtinger.felicitas.gloeth.HelloMessage
+	(*WelcomeMessage)(nil), // 4: com.pojtinger.felicitas.gloeth.WelcomeMessage
+	(*PeersMessage)(nil),   // 5: com.pojtinger.felicitas.gloeth.PeersMessage
+	(*BatchMessage)(nil),   // 6: com.pojtinger.felicitas.gloeth.BatchMessage
+	(*OffloadMessage)(nil), // 7: com.pojtinger.felicitas.gloeth.OffloadMessage
 }
 var file_frame_proto_depIdxs = []int32{
-	0, // 0: com.pojtinger.felicitas.gloeth.FrameService.TransceiveFrames:input_type -> com.pojtinger.felicitas.gloeth.FrameMessage
-	0, // 1: com.pojtinger.felicitas.gloeth.FrameService.TransceiveFrames:output_type -> com.pojtinger.felicitas.gloeth.FrameMessage
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3,  // 0: com.pojtinger.felicitas.gloeth.FrameMessage.Hello:type_name -> com.pojtinger.felicitas.gloeth.HelloMessage
+	4,  // 1: com.pojtinger.felicitas.gloeth.FrameMessage.Welcome:type_name -> com.pojtinger.felicitas.gloeth.WelcomeMessage
+	5,  // 2: com.pojtinger.felicitas.gloeth.FrameMessage.Peers:type_name -> com.pojtinger.felicitas.gloeth.PeersMessage
+	6,  // 3: com.pojtinger.felicitas.gloeth.FrameMessage.Batch:type_name -> com.pojtinger.felicitas.gloeth.BatchMessage
+	0,  // 4: com.pojtinger.felicitas.gloeth.FrameMessage.Compression:type_name -> com.pojtinger.felicitas.gloeth.Compression
+	7,  // 5: com.pojtinger.felicitas.gloeth.FrameMessage.Offload:type_name -> com.pojtinger.felicitas.gloeth.OffloadMessage
+	1,  // 6: com.pojtinger.felicitas.gloeth.FrameMessage.PayloadType:type_name -> com.pojtinger.felicitas.gloeth.PayloadType
+	0,  // 7: com.pojtinger.felicitas.gloeth.HelloMessage.Compressions:type_name -> com.pojtinger.felicitas.gloeth.Compression
+	1,  // 8: com.pojtinger.felicitas.gloeth.HelloMessage.PayloadType:type_name -> com.pojtinger.felicitas.gloeth.PayloadType
+	0,  // 9: com.pojtinger.felicitas.gloeth.WelcomeMessage.Compression:type_name -> com.pojtinger.felicitas.gloeth.Compression
+	2,  // 10: com.pojtinger.felicitas.gloeth.BatchMessage.Frames:type_name -> com.pojtinger.felicitas.gloeth.FrameMessage
+	2,  // 11: com.pojtinger.felicitas.gloeth.FrameService.TransceiveFrames:input_type -> com.pojtinger.felicitas.gloeth.FrameMessage
+	2,  // 12: com.pojtinger.felicitas.gloeth.FrameService.TransceiveFrames:output_type -> com.pojtinger.felicitas.gloeth.FrameMessage
+	12, // [12:13] is the sub-list for method output_type
+	11, // [11:12] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_frame_proto_init() }
@@ -152,19 +775,80 @@ func file_frame_proto_init() {
 				return nil
 			}
 		}
+		file_frame_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HelloMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frame_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WelcomeMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frame_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeersMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frame_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frame_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OffloadMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_frame_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   1,
+			NumEnums:      2,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_frame_proto_goTypes,
 		DependencyIndexes: file_frame_proto_depIdxs,
+		EnumInfos:         file_frame_proto_enumTypes,
 		MessageInfos:      file_frame_proto_msgTypes,
 	}.Build()
 	File_frame_proto = out.File
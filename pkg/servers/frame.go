@@ -1,50 +1,80 @@
 package servers
 
 import (
-	"net"
+	"errors"
+	"log"
+	"os"
+	"sync"
 
-	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/lifecycles"
 	"github.com/pojntfx/gloeth/pkg/services"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/reflection"
+	"github.com/pojntfx/gloeth/pkg/transports"
 )
 
 type FrameServer struct {
-	listenAddress string
-	certificate   string
-	key           string
-	frameService  *services.FrameService
+	transport    transports.Transport
+	frameService *services.FrameService
+	listener     transports.Listener
+	lifecycle    *lifecycles.Lifecycle
+	lock         sync.Mutex
 }
 
-func NewFrameServer(listenAddress string, certificate string, key string, frameService *services.FrameService) *FrameServer {
-	return &FrameServer{listenAddress, certificate, key, frameService}
+func NewFrameServer(transport transports.Transport, frameService *services.FrameService) *FrameServer {
+	return &FrameServer{transport, frameService, nil, lifecycles.NewLifecycle(), sync.Mutex{}}
 }
 
+// Open accepts streams until the server is closed, in which case it returns nil
 func (s *FrameServer) Open() error {
-	listenAddress, err := net.ResolveTCPAddr("tcp", s.listenAddress)
+	listener, err := s.transport.Listen()
 	if err != nil {
 		return err
 	}
+	defer listener.Close()
 
-	listener, err := net.ListenTCP("tcp", listenAddress)
-	if err != nil {
-		return err
+	s.lock.Lock()
+	select {
+	case <-s.lifecycle.Closed():
+		s.lock.Unlock()
+
+		return nil
+	default:
 	}
+	s.listener = listener
+	s.lock.Unlock()
 
-	creds, err := credentials.NewServerTLSFromFile(s.certificate, s.key)
-	if err != nil {
-		return err
+	s.lifecycle.Open()
+
+	for {
+		stream, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.lifecycle.Closed():
+				return nil
+			default:
+				return err
+			}
+		}
+
+		go func() {
+			if err := s.frameService.TransceiveFrames(stream); err != nil && !errors.Is(err, os.ErrClosed) {
+				log.Printf("Closed session for peer %q: %v", stream.Identity(), err)
+			}
+		}()
 	}
+}
 
-	server := grpc.NewServer(grpc.Creds(creds))
+// Close stops accepting streams; streams which have already been accepted are closed by the frame service
+func (s *FrameServer) Close() error {
+	if !s.lifecycle.Close() {
+		return nil
+	}
 
-	reflection.Register(server)
-	proto.RegisterFrameServiceServer(server, s.frameService)
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	if err := server.Serve(listener); err != nil {
-		return err
+	if s.listener == nil {
+		return nil
 	}
 
-	return nil
+	return s.listener.Close()
 }
@@ -0,0 +1,196 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/batchers"
+	"github.com/pojntfx/gloeth/pkg/clients"
+	"github.com/pojntfx/gloeth/pkg/converters"
+	"github.com/pojntfx/gloeth/pkg/fixtures"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/services"
+	"github.com/pojntfx/gloeth/pkg/transports"
+)
+
+var (
+	broadcastAddress = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	hubAddress       = []byte{0x02, 0, 0, 0, 0, 0x01}
+	spokeAddress     = []byte{0x02, 0, 0, 0, 0, 0x02}
+)
+
+// newTestFrameServer returns an open frame server which accepts fixtures.PreSharedKey
+func newTestFrameServer(t testing.TB, transport transports.Transport) (*FrameServer, *services.FrameService) {
+	t.Helper()
+
+	frameService := fixtures.NewFrameService(t, converters.NewFrameConverter(nil, pools.NewFramePool(2048), false, proto.PayloadType_ETHERNET), transport.MaximumBatchLength(), nil)
+	frameServer := NewFrameServer(transport, frameService)
+
+	opened := make(chan error, 1)
+	go func() {
+		opened <- frameServer.Open()
+	}()
+
+	t.Cleanup(func() {
+		if err := frameService.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if err := frameServer.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if err := <-opened; err != nil {
+			t.Error(err)
+		}
+	})
+
+	return frameServer, frameService
+}
+
+func TestFrameServerRoundTrip(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+	_, frameService := newTestFrameServer(t, transport)
+
+	frameClient := clients.NewFrameClient(transport, fixtures.PreSharedKey, nil, []byte("spoke"), nil, false, proto.PayloadType_ETHERNET)
+	if err := frameClient.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer frameClient.Close()
+
+	// Broadcasts are flooded to the hub's own port, which learns the spoke's address from them
+	request := fixtures.NewEthernetFrame(broadcastAddress, spokeAddress, 1, 2, 3)
+	if err := frameClient.Write(context.Background(), &proto.FrameMessage{Content: request, PayloadType: proto.PayloadType_ETHERNET}); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := frameService.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received.Content, request) {
+		t.Fatalf("expected frame %x, got %x", request, received.Content)
+	}
+
+	reply := fixtures.NewEthernetFrame(spokeAddress, hubAddress, 1, 2, 3)
+	if err := frameService.Write(&proto.FrameMessage{Content: reply, PayloadType: proto.PayloadType_ETHERNET}); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err = frameClient.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received.Content, reply) {
+		t.Fatalf("expected frame %x, got %x", reply, received.Content)
+	}
+}
+
+func TestFrameServerRejectsInvalidPreSharedKeys(t *testing.T) {
+	transport := transports.NewMemoryTransport()
+	newTestFrameServer(t, transport)
+
+	frameClient := clients.NewFrameClient(transport, "invalid-pre-shared-key", nil, []byte("spoke"), nil, false, proto.PayloadType_ETHERNET)
+	defer frameClient.Close()
+
+	if err := frameClient.Open(); !errors.Is(err, transports.ErrUnauthenticated) {
+		t.Fatalf("expected %v, got %v", transports.ErrUnauthenticated, err)
+	}
+}
+
+func BenchmarkFrameServerSendPath(b *testing.B) {
+	transport := transports.NewMemoryTransport()
+	_, frameService := newTestFrameServer(b, transport)
+
+	framePool := pools.NewFramePool(2048)
+	frameConverter := converters.NewFrameConverter(nil, framePool, false, proto.PayloadType_ETHERNET)
+
+	frameClient := clients.NewFrameClient(transport, fixtures.PreSharedKey, nil, []byte("spoke"), nil, false, proto.PayloadType_ETHERNET)
+	if err := frameClient.Open(); err != nil {
+		b.Fatal(err)
+	}
+	defer frameClient.Close()
+
+	frameBatcher := batchers.NewFrameBatcher(32, transport.MaximumBatchLength(), time.Millisecond, frameClient, framePool)
+
+	// Broadcasts from the only spoke are only flooded to the hub's own port
+	rawFrame := make([]byte, 1514)
+	copy(rawFrame, fixtures.NewEthernetFrame(broadcastAddress, spokeAddress, 1, 2, 3))
+
+	// The hub drops frames if its device can't keep up, so the end of the benchmark is marked instead of counting frames
+	end := make([]byte, len(rawFrame))
+	copy(end, rawFrame)
+	end[len(end)-1] = 0xff
+
+	received := make(chan int, 1)
+	go func() {
+		count := 0
+		for {
+			frame, err := frameService.Read()
+			if err != nil || bytes.Equal(frame.Content, end) {
+				received <- count
+
+				return
+			}
+
+			count++
+		}
+	}()
+
+	// Reused for every frame, as on the device reader's path
+	frames := []*proto.FrameMessage{}
+
+	send := func(rawFrame []byte) {
+		buffer := framePool.GetBuffer()
+		buffer = buffer[:copy(buffer, rawFrame)]
+
+		var err error
+		frames, err = frameConverter.ToExternal(frames[:0], buffer, frameClient.Compression(), frameClient.Offload())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, frame := range frames {
+			if err := frameBatcher.Write(context.Background(), frame); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(rawFrame)))
+	b.ResetTimer()
+
+	// The same path which frames take from a spoke's network device to the hub's
+	for i := 0; i < b.N; i++ {
+		send(rawFrame)
+	}
+
+	if err := frameBatcher.Flush(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+
+	b.StopTimer()
+
+	for {
+		send(end)
+
+		if err := frameBatcher.Flush(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+
+		select {
+		case count := <-received:
+			b.ReportMetric(float64(b.N-count)/float64(b.N), "drops/op")
+
+			return
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
@@ -1,44 +1,616 @@
 package services
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/batchers"
+	"github.com/pojntfx/gloeth/pkg/converters"
+	"github.com/pojntfx/gloeth/pkg/hashers"
+	"github.com/pojntfx/gloeth/pkg/parsers"
 	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/tables"
+	"github.com/pojntfx/gloeth/pkg/transports"
+	"github.com/pojntfx/gloeth/pkg/validators"
 )
 
 //go:generate sh -c "mkdir -p ../proto/generated && protoc --go_out=paths=source_relative,plugins=grpc:../proto/generated -I=../proto ../proto/*.proto"
 
+const (
+	frameBufferSize      = 1024
+	handshakeTimeout     = time.Second * 10
+	ethernetHeaderLength = 14
+	ipHeaderLength       = 40
+	localPortID          = 0
+)
+
+// Streams which are rejected are closed with these errors, so that peers can tell them apart from disconnects
+var (
+	ErrPeerNotAllowed      = fmt.Errorf("%w: peer not allowed", transports.ErrPermissionDenied)
+	ErrHandshakeFailed     = fmt.Errorf("%w: handshake failed", transports.ErrUnauthenticated)
+	ErrInvalidPreSharedKey = fmt.Errorf("%w: invalid pre-shared key", transports.ErrUnauthenticated)
+)
+
 type FrameService struct {
-	proto.UnimplementedFrameServiceServer
-	channel proto.FrameService_TransceiveFramesServer
+	forwardingTable       *tables.ForwardingTable
+	routingTable          *tables.ForwardingTable
+	identityValidator     *validators.IdentityValidator
+	preSharedKeyValidator *validators.PreSharedKeyValidator
+	frameConverter        *converters.FrameConverter
+	publicKey             []byte
+	maximumBatchFrames    int
+	maximumBatchLength    int
+	compressions          []proto.Compression
+	payloadType           proto.PayloadType
+	ports                 map[int64]*port
+	peerIDs               map[string]int64
+	publicKeys            map[string]int64
+	sessionsLock          sync.Mutex
+	nextPortID            int64
+	nextSessionID         int64
+	frames                chan *proto.FrameMessage
+	droppedReplays        uint64
+	done                  chan struct{}
+	closeOnce             sync.Once
+	streams               sync.WaitGroup
 }
 
-func NewFrameService() *FrameService {
-	return &FrameService{}
+// port groups the sessions of a peer, which can open multiple streams to spread its flows across them
+type port struct {
+	id          int64
+	identity    string
+	publicKey   []byte
+	peerID      []byte
+	payloadType proto.PayloadType
+	sessions    []*session
 }
 
-func (s *FrameService) TransceiveFrames(channel proto.FrameService_TransceiveFramesServer) error {
-	wait := make(chan struct{})
+type session struct {
+	id          int64
+	port        *port
+	compression proto.Compression
+	offload     bool
+	frames      chan *proto.FrameMessage
+}
 
-	s.channel = channel
+// FrameServiceConfig configures a frame service
+type FrameServiceConfig struct {
+	// The local end-to-end encryption key; nil if end-to-end encryption is disabled
+	PublicKey []byte
 
-	<-wait // Wait forever
+	MaximumBatchFrames int
+	MaximumBatchLength int
 
-	return nil
+	// The payload compressions which peers may choose from
+	Compressions []proto.Compression
+
+	// The type of the local device's frames
+	PayloadType proto.PayloadType
+}
+
+// NewFrameService creates a hub. Ethernet frames are switched with forwardingTable and IP packets are routed with routingTable,
+// so that a hub can serve both TAP and TUN peers.
+func NewFrameService(forwardingTable *tables.ForwardingTable, routingTable *tables.ForwardingTable, identityValidator *validators.IdentityValidator, preSharedKeyValidator *validators.PreSharedKeyValidator, frameConverter *converters.FrameConverter, config FrameServiceConfig) *FrameService {
+	return &FrameService{
+		forwardingTable:       forwardingTable,
+		routingTable:          routingTable,
+		identityValidator:     identityValidator,
+		preSharedKeyValidator: preSharedKeyValidator,
+		frameConverter:        frameConverter,
+		publicKey:             config.PublicKey,
+		maximumBatchFrames:    config.MaximumBatchFrames,
+		maximumBatchLength:    config.MaximumBatchLength,
+		compressions:          config.Compressions,
+		payloadType:           config.PayloadType,
+		ports:                 make(map[int64]*port),
+		peerIDs:               make(map[string]int64),
+		publicKeys:            make(map[string]int64),
+		frames:                make(chan *proto.FrameMessage, frameBufferSize),
+		done:                  make(chan struct{}),
+	}
+}
+
+func (s *FrameService) TransceiveFrames(stream transports.Stream) error {
+	s.sessionsLock.Lock()
+	select {
+	case <-s.done:
+		s.sessionsLock.Unlock()
+
+		_ = stream.Close()
+
+		return os.ErrClosed
+	default:
+	}
+	s.streams.Add(1)
+	s.sessionsLock.Unlock()
+
+	// Registered first so that Close only returns once the stream has been closed
+	defer s.streams.Done()
+	defer stream.Close()
+
+	identity := stream.Identity()
+	if valid := s.identityValidator.Validate(identity); !valid {
+		_ = stream.CloseWithError(ErrPeerNotAllowed)
+
+		return ErrPeerNotAllowed
+	}
+
+	hello, compression, err := s.handshake(stream)
+	if err != nil {
+		_ = stream.CloseWithError(err)
+
+		return err
+	}
+
+	session := s.openSession(identity, hello, compression)
+	defer s.closeSession(session)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		replayValidator := validators.NewReplayValidator()
+
+		for {
+			frame, err := stream.Recv()
+			if err != nil {
+				errs <- err
+
+				return
+			}
+
+			if valid := replayValidator.Validate(frame.Sequence); !valid {
+				atomic.AddUint64(&s.droppedReplays, 1)
+
+				continue
+			}
+
+			frames := []*proto.FrameMessage{frame}
+			if frame.Batch != nil {
+				frames = frame.Batch.Frames
+			}
+
+			for _, frame := range frames {
+				// Peers can only send in their own name and with the payload type they announced
+				if frame.PayloadType != session.port.payloadType || len(frame.Content) == 0 {
+					continue
+				}
+
+				// Only the data fields are forwarded, so that peers can't send control messages, i.e. peer announcements, in the hub's name
+				s.forward(session.port.id, &proto.FrameMessage{
+					Content:     frame.Content,
+					Sender:      session.port.publicKey,
+					Recipient:   frame.Recipient,
+					Compression: frame.Compression,
+					Offload:     frame.Offload,
+					PayloadType: frame.PayloadType,
+				})
+			}
+		}
+	}()
+
+	sequence := uint64(0)
+	send := func(frame *proto.FrameMessage) error {
+		for _, batch := range s.batch(session, frame) {
+			sequence++
+
+			if err := stream.Send(withSequence(batch, sequence)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for {
+		select {
+		case frame := <-session.frames:
+			if err := send(frame); err != nil {
+				return err
+			}
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		case <-s.done:
+			// Frames which are already queued are still sent before the stream is closed
+			for {
+				select {
+				case frame := <-session.frames:
+					if err := send(frame); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+			}
+		}
+	}
 }
 
 func (s *FrameService) Write(frame *proto.FrameMessage) error {
-	s.waitTillOpen()
+	select {
+	case <-s.done:
+		return os.ErrClosed
+	default:
+	}
 
-	return s.channel.Send(frame)
+	s.forward(localPortID, frame)
+
+	return nil
 }
 
+// Read returns os.ErrClosed once the service has been closed and all frames which were queued for the local port have been read
 func (s *FrameService) Read() (*proto.FrameMessage, error) {
-	s.waitTillOpen()
+	select {
+	case frame := <-s.frames:
+		return frame, nil
+	case <-s.done:
+		select {
+		case frame := <-s.frames:
+			return frame, nil
+		default:
+			return nil, os.ErrClosed
+		}
+	}
+}
+
+// Close stops accepting sessions and waits until the frames which are queued for each session have been sent and its stream has been closed
+func (s *FrameService) Close() error {
+	s.sessionsLock.Lock()
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	s.sessionsLock.Unlock()
+
+	s.streams.Wait()
+
+	return nil
+}
+
+func (s *FrameService) DroppedReplays() uint64 {
+	return atomic.LoadUint64(&s.droppedReplays)
+}
+
+// handshake authenticates a stream once before any frames are exchanged and negotiates its compression; offloads
+// are accepted from every peer which supports them, as the hub can segment frames for peers which don't
+func (s *FrameService) handshake(stream transports.Stream) (*proto.HelloMessage, proto.Compression, error) {
+	// Peers which don't complete the handshake in time or before the service is closed are disconnected
+	handshaken := make(chan struct{})
+	defer close(handshaken)
+
+	go func() {
+		timer := time.NewTimer(handshakeTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			_ = stream.Close()
+		case <-s.done:
+			_ = stream.Close()
+		case <-handshaken:
+		}
+	}()
+
+	hello, err := stream.Recv()
+	if err != nil {
+		return nil, proto.Compression_NONE, err
+	}
+
+	if hello.Hello == nil {
+		return nil, proto.Compression_NONE, ErrHandshakeFailed
+	}
+
+	if valid := s.preSharedKeyValidator.Validate(hello.Hello.PreSharedKey); !valid {
+		return nil, proto.Compression_NONE, ErrInvalidPreSharedKey
+	}
+
+	compression := s.negotiateCompression(hello.Hello.Compressions)
+
+	if err := stream.Send(&proto.FrameMessage{Welcome: &proto.WelcomeMessage{Compression: compression, Offload: hello.Hello.Offload}}); err != nil {
+		return nil, proto.Compression_NONE, err
+	}
+
+	return hello.Hello, compression, nil
+}
+
+// negotiateCompression picks the peer's most preferred compression which is also enabled locally
+func (s *FrameService) negotiateCompression(offered []proto.Compression) proto.Compression {
+	for _, candidate := range offered {
+		for _, compression := range s.compressions {
+			if candidate == compression {
+				return compression
+			}
+		}
+	}
+
+	return proto.Compression_NONE
+}
+
+// openSession adds a session to the port of the peer which opened it, creating the port for the peer's first session
+func (s *FrameService) openSession(identity string, hello *proto.HelloMessage, compression proto.Compression) *session {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+
+	var sessionPort *port
+	if id, ok := s.peerIDs[string(hello.PeerID)]; ok && len(hello.PeerID) > 0 {
+		// Peer IDs aren't secret, so other peers may only join if they have the same identity
+		if existing := s.ports[id]; existing.identity == identity && bytes.Equal(existing.publicKey, hello.PublicKey) {
+			sessionPort = existing
+		}
+	}
+
+	if sessionPort == nil {
+		s.nextPortID++
+
+		sessionPort = &port{s.nextPortID, identity, hello.PublicKey, hello.PeerID, hello.PayloadType, []*session{}}
+
+		s.ports[sessionPort.id] = sessionPort
+		if len(sessionPort.peerID) > 0 {
+			s.peerIDs[string(sessionPort.peerID)] = sessionPort.id
+		}
+		if len(sessionPort.publicKey) > 0 {
+			s.publicKeys[string(sessionPort.publicKey)] = sessionPort.id
+		}
+	}
+
+	s.nextSessionID++
+
+	session := &session{s.nextSessionID, sessionPort, compression, hello.Offload, make(chan *proto.FrameMessage, frameBufferSize)}
+
+	sessionPort.sessions = append(sessionPort.sessions, session)
+
+	log.Printf("Opened session %v on port %v for peer %q with payload type %v, compression %v and offloads %v", session.id, sessionPort.id, sessionPort.identity, sessionPort.payloadType, session.compression, session.offload)
+
+	s.announcePeers()
 
-	return s.channel.Recv()
+	return session
 }
 
-func (s *FrameService) waitTillOpen() {
-	for s.channel == nil {
+func (s *FrameService) closeSession(session *session) {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+
+	sessionPort := session.port
+
+	for i, candidate := range sessionPort.sessions {
+		if candidate == session {
+			sessionPort.sessions = append(sessionPort.sessions[:i], sessionPort.sessions[i+1:]...)
+
+			break
+		}
+	}
+
+	log.Printf("Closed session %v on port %v for peer %q", session.id, sessionPort.id, sessionPort.identity)
+
+	if len(sessionPort.sessions) == 0 {
+		delete(s.ports, sessionPort.id)
+		if id, ok := s.peerIDs[string(sessionPort.peerID)]; ok && id == sessionPort.id {
+			delete(s.peerIDs, string(sessionPort.peerID))
+		}
+		if id, ok := s.publicKeys[string(sessionPort.publicKey)]; ok && id == sessionPort.id {
+			delete(s.publicKeys, string(sessionPort.publicKey))
+		}
+
+		s.forwardingTable.Forget(sessionPort.id)
+		s.routingTable.Forget(sessionPort.id)
+	}
+
+	s.announcePeers()
+}
+
+// announcePeers sends the public keys of all end-to-end encrypting peers to each of them; peers only learn about peers
+// with the same payload type, as they couldn't exchange frames anyway. The sessions lock must be held.
+func (s *FrameService) announcePeers() {
+	publicKeys := map[proto.PayloadType][][]byte{}
+	if len(s.publicKey) > 0 {
+		publicKeys[s.payloadType] = append(publicKeys[s.payloadType], s.publicKey)
+	}
+
+	for _, candidate := range s.ports {
+		if len(candidate.publicKey) > 0 {
+			publicKeys[candidate.payloadType] = append(publicKeys[candidate.payloadType], candidate.publicKey)
+		}
+	}
+
+	if len(s.publicKey) > 0 {
+		enqueue(s.frames, &proto.FrameMessage{Peers: &proto.PeersMessage{PublicKeys: publicKeys[s.payloadType]}})
+	}
+
+	for _, destination := range s.ports {
+		if len(destination.publicKey) == 0 {
+			continue
+		}
+
+		peers := &proto.FrameMessage{Peers: &proto.PeersMessage{PublicKeys: publicKeys[destination.payloadType]}}
+
+		for _, session := range destination.sessions {
+			enqueue(session.frames, peers)
+		}
+	}
+}
+
+// forward switches Ethernet frames based on their destination hardware address and routes IP packets based on their destination
+// IP address, flooding them to every other port if the destination is a broadcast/multicast address or hasn't been learned yet
+func (s *FrameService) forward(sourceID int64, frame *proto.FrameMessage) {
+	if len(frame.Recipient) > 0 {
+		s.forwardToRecipient(frame)
+
+		return
+	}
+
+	destination, source, ok := parsers.Addresses(frame.PayloadType, frame.Content)
+	if !ok {
+		return
+	}
+
+	table := s.forwardingTable
+	if frame.PayloadType == proto.PayloadType_IP {
+		table = s.routingTable
+	}
+
+	if parsers.IsUnicast(frame.PayloadType, source) {
+		table.Learn(source, sourceID)
+	}
+
+	if parsers.IsUnicast(frame.PayloadType, destination) {
+		if destinationID, ok := table.Lookup(destination); ok {
+			if destinationID != sourceID {
+				s.deliver(destinationID, frame)
+			}
+
+			return
+		}
+	}
+
+	s.flood(sourceID, frame)
+}
+
+// forwardToRecipient routes end-to-end encrypted frames, whose content is opaque to the hub, by the recipient's public key
+func (s *FrameService) forwardToRecipient(frame *proto.FrameMessage) {
+	if bytes.Equal(frame.Recipient, s.publicKey) {
+		enqueue(s.frames, frame)
+
+		return
+	}
+
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+
+	if id, ok := s.publicKeys[string(frame.Recipient)]; ok {
+		enqueue(s.ports[id].session(frame).frames, frame)
+	}
+}
+
+func (s *FrameService) deliver(destinationID int64, frame *proto.FrameMessage) {
+	if destinationID == localPortID {
+		enqueue(s.frames, frame)
+
+		return
+	}
+
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+
+	if destination, ok := s.ports[destinationID]; ok {
+		enqueue(destination.session(frame).frames, frame)
+	}
+}
+
+// flood only sends frames to ports with the same payload type
+func (s *FrameService) flood(sourceID int64, frame *proto.FrameMessage) {
+	if sourceID != localPortID && frame.PayloadType == s.payloadType {
+		enqueue(s.frames, frame)
+	}
+
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+
+	for id, destination := range s.ports {
+		if id == sourceID || destination.payloadType != frame.PayloadType {
+			continue
+		}
+
+		enqueue(destination.session(frame).frames, frame)
+	}
+}
+
+// session picks the session to send a frame on by its flow; as the content after the header may be compressed, only the
+// header is hashed, which only contains the hardware addresses for Ethernet frames, and end-to-end encrypted frames are hashed by their sender
+func (p *port) session(frame *proto.FrameMessage) *session {
+	if len(p.sessions) == 1 {
+		return p.sessions[0]
+	}
+
+	var hash uint32
+	switch {
+	case len(frame.Recipient) > 0:
+		hash = hashers.HashBytes(frame.Sender)
+	case frame.PayloadType == proto.PayloadType_IP:
+		hash = hashers.HashPacket(frame.Content[:min(ipHeaderLength, len(frame.Content))])
+	default:
+		hash = hashers.HashFlow(frame.Content[:ethernetHeaderLength])
+	}
+
+	return p.sessions[hash%uint32(len(p.sessions))]
+}
+
+// batch opportunistically coalesces frames which are already queued for a session without waiting for more into batches
+// which don't exceed the maximum batch length; it returns none if none of the frames could be transcoded for the session
+func (s *FrameService) batch(session *session, frame *proto.FrameMessage) []*proto.FrameMessage {
+	frames := []*proto.FrameMessage{}
+
+	add := func(frame *proto.FrameMessage) {
+		transcoded, err := s.frameConverter.Transcode(frames, frame, session.compression, session.offload)
+		if err != nil {
+			log.Println("could not transcode frame, dropping frame", err)
+
+			return
+		}
+
+		frames = transcoded
+	}
+
+	add(frame)
+
+collect:
+	for len(frames) < s.maximumBatchFrames {
+		select {
+		case next := <-session.frames:
+			add(next)
+		default:
+			break collect
+		}
+	}
+
+	batches := []*proto.FrameMessage{}
+	start, length := 0, 0
+	for i, frame := range frames {
+		frameLength := batchers.Length(frame)
+		if i > start && !batchers.Fits(length+frameLength, s.maximumBatchLength) {
+			batches = append(batches, batchers.NewBatch(frames[start:i]))
+
+			start, length = i, 0
+		}
+
+		length += frameLength
+	}
+
+	if start < len(frames) {
+		batches = append(batches, batchers.NewBatch(frames[start:]))
+	}
+
+	return batches
+}
+
+// withSequence copies the frame, as the same frame can be queued for multiple sessions with their own sequence numbers
+func withSequence(frame *proto.FrameMessage, sequence uint64) *proto.FrameMessage {
+	return &proto.FrameMessage{
+		Content:     frame.Content,
+		Hello:       frame.Hello,
+		Welcome:     frame.Welcome,
+		Sender:      frame.Sender,
+		Recipient:   frame.Recipient,
+		Peers:       frame.Peers,
+		Sequence:    sequence,
+		Batch:       frame.Batch,
+		Compression: frame.Compression,
+		Offload:     frame.Offload,
+		PayloadType: frame.PayloadType,
+	}
+}
 
+// enqueue drops the frame instead of blocking if the receiver can't keep up
+func enqueue(frames chan *proto.FrameMessage, frame *proto.FrameMessage) {
+	select {
+	case frames <- frame:
+	default:
 	}
 }
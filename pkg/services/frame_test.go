@@ -0,0 +1,193 @@
+package services_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pojntfx/gloeth/pkg/converters"
+	"github.com/pojntfx/gloeth/pkg/fixtures"
+	"github.com/pojntfx/gloeth/pkg/pools"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/pojntfx/gloeth/pkg/services"
+	"github.com/pojntfx/gloeth/pkg/transports"
+)
+
+// newTestFrameService creates a service which lets peers choose from compressions
+func newTestFrameService(t *testing.T, compressions []proto.Compression) *services.FrameService {
+	t.Helper()
+
+	return fixtures.NewFrameService(t, converters.NewFrameConverter(nil, pools.NewFramePool(2048), false, proto.PayloadType_ETHERNET), transports.NewMemoryTransport().MaximumBatchLength(), compressions)
+}
+
+// connectTestStream connects a stream to the service; the service's result is sent to errs
+func connectTestStream(t *testing.T, frameService *services.FrameService, errs chan<- error) transports.Stream {
+	t.Helper()
+
+	transport := transports.NewMemoryTransport()
+
+	listener, err := transport.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		stream, err := listener.Accept()
+		if err != nil {
+			errs <- err
+
+			return
+		}
+
+		errs <- frameService.TransceiveFrames(stream)
+	}()
+
+	stream, err := transport.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stream
+}
+
+// openTestSession connects a stream for the peer to the service and completes its handshake, offering compressions; it returns the welcome as well
+func openTestSession(t *testing.T, frameService *services.FrameService, errs chan<- error, peerID string, compressions []proto.Compression) (transports.Stream, *proto.WelcomeMessage) {
+	t.Helper()
+
+	stream := connectTestStream(t, frameService, errs)
+
+	if err := stream.Send(&proto.FrameMessage{Hello: &proto.HelloMessage{PreSharedKey: fixtures.PreSharedKey, PeerID: []byte(peerID), Compressions: compressions}}); err != nil {
+		t.Fatal(err)
+	}
+
+	welcome, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if welcome.Welcome == nil {
+		t.Fatalf("expected a welcome, got %v", welcome)
+	}
+
+	return stream, welcome.Welcome
+}
+
+func TestFrameServiceCloseClosesSessions(t *testing.T) {
+	frameService := newTestFrameService(t, nil)
+
+	sessionErrs := make(chan error, 2)
+	streamA, _ := openTestSession(t, frameService, sessionErrs, "spoke-a", nil)
+	streamB, _ := openTestSession(t, frameService, sessionErrs, "spoke-b", nil)
+	streams := []transports.Stream{streamA, streamB}
+
+	readErrs := make(chan error, 1)
+	go func() {
+		_, err := frameService.Read()
+
+		readErrs <- err
+	}()
+
+	if err := frameService.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for range streams {
+		if err := <-sessionErrs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Close only returns once the streams have been closed
+	for _, stream := range streams {
+		if _, err := stream.Recv(); err == nil {
+			t.Fatal("expected the stream to be closed")
+		}
+	}
+
+	if err := <-readErrs; !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+
+	if err := frameService.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Streams which are accepted afterwards are closed immediately
+	connectTestStream(t, frameService, sessionErrs)
+
+	if err := <-sessionErrs; !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestFrameServiceNegotiatesCompressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  []proto.Compression
+		offered  []proto.Compression
+		expected proto.Compression
+	}{
+		{"peer's most preferred shared compression", []proto.Compression{proto.Compression_ZSTD, proto.Compression_LZ4}, []proto.Compression{proto.Compression_SNAPPY, proto.Compression_LZ4, proto.Compression_ZSTD}, proto.Compression_LZ4},
+		{"no shared compression", []proto.Compression{proto.Compression_ZSTD}, []proto.Compression{proto.Compression_LZ4, proto.Compression_SNAPPY}, proto.Compression_NONE},
+		{"no offered compression", []proto.Compression{proto.Compression_ZSTD}, nil, proto.Compression_NONE},
+		{"no enabled compression", nil, []proto.Compression{proto.Compression_ZSTD}, proto.Compression_NONE},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frameService := newTestFrameService(t, test.enabled)
+			defer frameService.Close()
+
+			_, welcome := openTestSession(t, frameService, make(chan error, 1), "spoke", test.offered)
+			if welcome.Compression != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, welcome.Compression)
+			}
+		})
+	}
+}
+
+func TestFrameServiceTranscodesBetweenPeersWithoutSharedCompressions(t *testing.T) {
+	frameService := newTestFrameService(t, []proto.Compression{proto.Compression_ZSTD})
+	defer frameService.Close()
+
+	sessionErrs := make(chan error, 2)
+	compressing, welcome := openTestSession(t, frameService, sessionErrs, "compressing", []proto.Compression{proto.Compression_ZSTD})
+	if welcome.Compression != proto.Compression_ZSTD {
+		t.Fatalf("expected %v, got %v", proto.Compression_ZSTD, welcome.Compression)
+	}
+
+	uncompressing, welcome := openTestSession(t, frameService, sessionErrs, "uncompressing", []proto.Compression{proto.Compression_LZ4})
+	if welcome.Compression != proto.Compression_NONE {
+		t.Fatalf("expected %v, got %v", proto.Compression_NONE, welcome.Compression)
+	}
+
+	// A compressible broadcast, which is flooded to the other peer
+	rawFrame := fixtures.NewEthernetFrame([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte{0x02, 0, 0, 0, 0, 0x0a}, bytes.Repeat([]byte("compressible"), 100)...)
+
+	framePool := pools.NewFramePool(2048)
+	buffer := framePool.GetBuffer()
+	frames, err := converters.NewFrameConverter(nil, framePool, false, proto.PayloadType_ETHERNET).ToExternal([]*proto.FrameMessage{}, buffer[:copy(buffer, rawFrame)], proto.Compression_ZSTD, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 1 || frames[0].Compression != proto.Compression_ZSTD {
+		t.Fatalf("expected a frame compressed with %v, got %v", proto.Compression_ZSTD, frames)
+	}
+
+	frames[0].Sequence = 1
+	if err := compressing.Send(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := uncompressing.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Compression != proto.Compression_NONE || !bytes.Equal(frame.Content, rawFrame) {
+		t.Fatalf("expected the uncompressed frame %x, got %x with %v", rawFrame, frame.Content, frame.Compression)
+	}
+}
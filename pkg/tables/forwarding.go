@@ -0,0 +1,116 @@
+package tables
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ForwardingEntry maps an address, which is a hardware address for Ethernet frames or an IP address for IP packets, to a port
+type ForwardingEntry struct {
+	Address  []byte
+	PortID   int64
+	LastSeen time.Time
+}
+
+type ForwardingTable struct {
+	maximumAge     time.Duration
+	maximumEntries int
+	entries        map[string]*ForwardingEntry
+	lastSweep      time.Time
+	lock           sync.Mutex
+}
+
+// NewForwardingTable creates a table which forgets addresses which haven't been seen for maximumAge and holds at most
+// maximumEntries of them, so that peers which send from ever-changing addresses can't exhaust the hub's memory
+func NewForwardingTable(maximumAge time.Duration, maximumEntries int) *ForwardingTable {
+	return &ForwardingTable{
+		maximumAge:     maximumAge,
+		maximumEntries: maximumEntries,
+		entries:        make(map[string]*ForwardingEntry),
+		lastSweep:      time.Now(),
+	}
+}
+
+func (t *ForwardingTable) Learn(address []byte, portID int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(t.lastSweep) > t.maximumAge {
+		t.sweep(now)
+	}
+
+	if entry, ok := t.entries[string(address)]; ok {
+		entry.PortID = portID
+		entry.LastSeen = now
+
+		return
+	}
+
+	// Addresses which can't be learned until others have aged out are flooded to, like those which haven't been learned yet
+	if len(t.entries) >= t.maximumEntries {
+		return
+	}
+
+	t.entries[string(address)] = &ForwardingEntry{append([]byte{}, address...), portID, now}
+}
+
+func (t *ForwardingTable) Lookup(address []byte) (int64, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	entry, ok := t.entries[string(address)]
+	if !ok {
+		return 0, false
+	}
+
+	if time.Since(entry.LastSeen) > t.maximumAge {
+		delete(t.entries, string(address))
+
+		return 0, false
+	}
+
+	return entry.PortID, true
+}
+
+func (t *ForwardingTable) Forget(portID int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for key, entry := range t.entries {
+		if entry.PortID == portID {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func (t *ForwardingTable) Dump() []ForwardingEntry {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.sweep(time.Now())
+
+	entries := []ForwardingEntry{}
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Address, entries[j].Address) < 0
+	})
+
+	return entries
+}
+
+func (t *ForwardingTable) sweep(now time.Time) {
+	for key, entry := range t.entries {
+		if now.Sub(entry.LastSeen) > t.maximumAge {
+			delete(t.entries, key)
+		}
+	}
+
+	t.lastSweep = now
+}
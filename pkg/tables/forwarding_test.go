@@ -0,0 +1,116 @@
+package tables
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var (
+	addressA = []byte{0x02, 0, 0, 0, 0, 0x0a}
+	addressB = []byte{0x02, 0, 0, 0, 0, 0x0b}
+	addressC = []byte{0x02, 0, 0, 0, 0, 0x0c}
+)
+
+func TestForwardingTableLearnsAddresses(t *testing.T) {
+	table := NewForwardingTable(time.Minute, 16)
+
+	if _, ok := table.Lookup(addressA); ok {
+		t.Fatal("expected the address not to have been learned yet")
+	}
+
+	table.Learn(addressA, 1)
+
+	if portID, ok := table.Lookup(addressA); !ok || portID != 1 {
+		t.Fatalf("expected port 1, got %v", portID)
+	}
+
+	// Addresses move with the hosts which own them
+	table.Learn(addressA, 2)
+
+	if portID, ok := table.Lookup(addressA); !ok || portID != 2 {
+		t.Fatalf("expected port 2, got %v", portID)
+	}
+}
+
+func TestForwardingTableForgetsAddressesWhichHaventBeenSeen(t *testing.T) {
+	table := NewForwardingTable(time.Millisecond*10, 16)
+
+	table.Learn(addressA, 1)
+
+	time.Sleep(time.Millisecond * 20)
+
+	if _, ok := table.Lookup(addressA); ok {
+		t.Fatal("expected the address to have been forgotten")
+	}
+
+	table.Learn(addressB, 1)
+
+	time.Sleep(time.Millisecond * 20)
+
+	if entries := table.Dump(); len(entries) != 0 {
+		t.Fatalf("expected no entries, got %v", entries)
+	}
+}
+
+func TestForwardingTableForgetsPorts(t *testing.T) {
+	table := NewForwardingTable(time.Minute, 16)
+
+	table.Learn(addressA, 1)
+	table.Learn(addressB, 2)
+	table.Learn(addressC, 1)
+
+	table.Forget(1)
+
+	entries := table.Dump()
+	if len(entries) != 1 || !bytes.Equal(entries[0].Address, addressB) || entries[0].PortID != 2 {
+		t.Fatalf("expected only the entry of port 2, got %v", entries)
+	}
+}
+
+func TestForwardingTableDumpsEntriesInOrder(t *testing.T) {
+	table := NewForwardingTable(time.Minute, 16)
+
+	for i, address := range [][]byte{addressC, addressA, addressB} {
+		table.Learn(address, int64(i))
+	}
+
+	entries := table.Dump()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %v", entries)
+	}
+
+	for i, address := range [][]byte{addressA, addressB, addressC} {
+		if !bytes.Equal(entries[i].Address, address) {
+			t.Fatalf("expected entry %v to be %x, got %x", i, address, entries[i].Address)
+		}
+	}
+}
+
+func TestForwardingTableLimitsEntries(t *testing.T) {
+	table := NewForwardingTable(time.Millisecond*50, 2)
+
+	table.Learn(addressA, 1)
+	table.Learn(addressB, 1)
+	table.Learn(addressC, 1)
+
+	if _, ok := table.Lookup(addressC); ok {
+		t.Fatal("expected the address not to be learned once the table is full")
+	}
+
+	// Learned addresses can still move to other ports
+	table.Learn(addressA, 2)
+
+	if portID, ok := table.Lookup(addressA); !ok || portID != 2 {
+		t.Fatalf("expected port 2, got %v", portID)
+	}
+
+	// Addresses can be learned again once others have been forgotten
+	time.Sleep(time.Millisecond * 100)
+
+	table.Learn(addressC, 1)
+
+	if _, ok := table.Lookup(addressC); !ok {
+		t.Fatal("expected the address to be learned once others have been forgotten")
+	}
+}
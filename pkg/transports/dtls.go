@@ -0,0 +1,407 @@
+package transports
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/transport/v2/udp"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const (
+	maximumDatagramSize = 65535
+	keepaliveInterval   = time.Second * 15
+	idleTimeout         = keepaliveInterval * 4
+	retransmitInterval  = time.Millisecond * 250
+	controlTimeout      = time.Second * 10
+	receiveBufferSize   = 1024
+)
+
+var (
+	ErrNotAcknowledged = errors.New("control message not acknowledged")
+)
+
+type DTLSTransport struct {
+	address   string
+	tlsConfig *tls.Config
+}
+
+func NewDTLSTransport(address string, tlsConfig *tls.Config) *DTLSTransport {
+	return &DTLSTransport{address, tlsConfig}
+}
+
+func (t *DTLSTransport) Dial() (Stream, error) {
+	remoteAddress, err := net.ResolveUDPAddr("udp", t.address)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := dtls.Dial("udp", remoteAddress, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDatagramStream(connection, dtlsIdentity(connection), keepaliveInterval, idleTimeout), nil
+}
+
+func (t *DTLSTransport) Listen() (Listener, error) {
+	listenAddress, err := net.ResolveUDPAddr("udp", t.address)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := (&udp.ListenConfig{}).Listen("udp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	dtlsListener := &datagramListener{
+		listener: listener,
+		streams:  make(chan Stream),
+		errs:     make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				dtlsListener.errs <- err
+
+				return
+			}
+
+			// Handshake concurrently so that a single slow peer can't block the others
+			go func() {
+				dtlsConnection, err := dtls.Server(connection, config)
+				if err != nil {
+					_ = connection.Close()
+
+					return
+				}
+
+				dtlsListener.accept(newDatagramStream(dtlsConnection, dtlsIdentity(dtlsConnection), keepaliveInterval, idleTimeout))
+			}()
+		}
+	}()
+
+	return dtlsListener, nil
+}
+
+func (t *DTLSTransport) MaximumBatchLength() int {
+	return datagramBatchLength
+}
+
+func (t *DTLSTransport) config() (*dtls.Config, error) {
+	serverName := t.tlsConfig.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(t.address)
+		if err != nil {
+			return nil, err
+		}
+
+		serverName = host
+	}
+
+	config := &dtls.Config{
+		Certificates:         t.tlsConfig.Certificates,
+		RootCAs:              t.tlsConfig.RootCAs,
+		ClientCAs:            t.tlsConfig.ClientCAs,
+		ServerName:           serverName,
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	}
+
+	if t.tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert {
+		config.ClientAuth = dtls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+func dtlsIdentity(connection *dtls.Conn) string {
+	certificates := []*x509.Certificate{}
+	for _, rawCertificate := range connection.ConnectionState().PeerCertificates {
+		certificate, err := x509.ParseCertificate(rawCertificate)
+		if err != nil {
+			return ""
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	return identityFromCertificates(certificates)
+}
+
+type datagramListener struct {
+	listener  net.Listener
+	streams   chan Stream
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *datagramListener) accept(stream Stream) {
+	select {
+	case l.streams <- stream:
+	case <-l.done:
+		_ = stream.Close()
+	}
+}
+
+func (l *datagramListener) Accept() (Stream, error) {
+	select {
+	case stream := <-l.streams:
+		return stream, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, io.EOF
+	}
+}
+
+func (l *datagramListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+
+		err = l.listener.Close()
+	})
+
+	return err
+}
+
+// datagramStream sends every frame as its own datagram; empty datagrams are used as keepalives.
+// Control messages are numbered and retransmitted until the peer acknowledges them.
+type datagramStream struct {
+	connection              net.Conn
+	identity                string
+	keepaliveInterval       time.Duration
+	idleTimeout             time.Duration
+	buffer                  []byte
+	sendBuffer              []byte
+	sendLock                sync.Mutex
+	controlSequence         uint64
+	controlLock             sync.Mutex
+	receivedControlSequence uint64
+	acknowledgements        chan uint64
+	frames                  chan *proto.FrameMessage
+	errs                    chan error
+	done                    chan struct{}
+	closeOnce               sync.Once
+}
+
+// newDatagramStream closes the stream if nothing, not even a keepalive, has been received for idleTimeout;
+// both peers send keepalives, as either of them may have nothing else to send
+func newDatagramStream(connection net.Conn, identity string, keepaliveInterval time.Duration, idleTimeout time.Duration) *datagramStream {
+	stream := &datagramStream{
+		connection:        connection,
+		identity:          identity,
+		keepaliveInterval: keepaliveInterval,
+		idleTimeout:       idleTimeout,
+		buffer:            make([]byte, maximumDatagramSize),
+		sendBuffer:        make([]byte, 0, maximumDatagramSize),
+		acknowledgements:  make(chan uint64, 1),
+		frames:            make(chan *proto.FrameMessage, receiveBufferSize),
+		errs:              make(chan error, 1),
+		done:              make(chan struct{}),
+	}
+
+	// Acknowledgements are received even while nobody reads from the stream, i.e. while the handshake waits for one
+	go stream.receive()
+	go stream.keepalive()
+
+	return stream
+}
+
+func (s *datagramStream) Send(frame *proto.FrameMessage) error {
+	if isControl(frame) {
+		return s.sendReliably(frame)
+	}
+
+	return s.send(frame)
+}
+
+func (s *datagramStream) send(frame *proto.FrameMessage) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	rawFrame, err := protobuf.MarshalOptions{}.MarshalAppend(s.sendBuffer[:0], frame)
+	if err != nil {
+		return err
+	}
+	s.sendBuffer = rawFrame
+
+	_, err = s.connection.Write(rawFrame)
+
+	return err
+}
+
+// sendReliably blocks until the peer has acknowledged the frame, so control messages are also received in order
+func (s *datagramStream) sendReliably(frame *proto.FrameMessage) error {
+	s.controlLock.Lock()
+	defer s.controlLock.Unlock()
+
+	s.controlSequence++
+
+	// The frame is copied, as the same frame can be sent on multiple streams
+	control := protobuf.Clone(frame).(*proto.FrameMessage)
+	control.ControlSequence = s.controlSequence
+
+	ticker := time.NewTicker(retransmitInterval)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(controlTimeout)
+	defer timer.Stop()
+
+	for {
+		if err := s.send(control); err != nil {
+			return err
+		}
+
+	wait:
+		for {
+			select {
+			case acknowledgement := <-s.acknowledgements:
+				if acknowledgement == control.ControlSequence {
+					return nil
+				}
+			case <-ticker.C:
+				break wait
+			case <-timer.C:
+				return ErrNotAcknowledged
+			case <-s.done:
+				return net.ErrClosed
+			}
+		}
+	}
+}
+
+func (s *datagramStream) Recv() (*proto.FrameMessage, error) {
+	select {
+	case frame := <-s.frames:
+		return frame, nil
+	case err := <-s.errs:
+		return nil, err
+	case <-s.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *datagramStream) receive() {
+	for {
+		if err := s.connection.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			s.errs <- err
+
+			return
+		}
+
+		n, err := s.connection.Read(s.buffer)
+		if err != nil {
+			s.errs <- err
+
+			return
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		frame := &proto.FrameMessage{}
+		if err := protobuf.Unmarshal(s.buffer[:n], frame); err != nil {
+			s.errs <- err
+
+			return
+		}
+
+		if frame.Acknowledgement != 0 {
+			// Acknowledgements of retransmissions which nobody waits for anymore are dropped
+			select {
+			case s.acknowledgements <- frame.Acknowledgement:
+			default:
+			}
+
+			continue
+		}
+
+		if frame.ControlSequence != 0 {
+			// Retransmissions of control messages which have already been received are only acknowledged again
+			if frame.ControlSequence > s.receivedControlSequence {
+				// Control messages which can't be queued aren't acknowledged, so that the peer retransmits them
+				select {
+				case s.frames <- frame:
+				default:
+					continue
+				}
+
+				s.receivedControlSequence = frame.ControlSequence
+			}
+
+			if err := s.send(&proto.FrameMessage{Acknowledgement: frame.ControlSequence}); err != nil {
+				s.errs <- err
+
+				return
+			}
+
+			continue
+		}
+
+		// Frames are dropped like lost datagrams if nobody reads them, so that acknowledgements are never blocked
+		select {
+		case s.frames <- frame:
+		default:
+		}
+	}
+}
+
+func (s *datagramStream) Identity() string {
+	return s.identity
+}
+
+func (s *datagramStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		err = s.connection.Close()
+	})
+
+	return err
+}
+
+// CloseWithError closes the stream normally, as datagrams can't tell the peer why
+func (s *datagramStream) CloseWithError(err error) error {
+	return s.Close()
+}
+
+func (s *datagramStream) keepalive() {
+	ticker := time.NewTicker(s.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.connection.Write([]byte{}); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
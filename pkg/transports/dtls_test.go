@@ -0,0 +1,174 @@
+package transports
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+// lossyConnection drops the first writes, like a network which loses datagrams
+type lossyConnection struct {
+	net.Conn
+	drops int
+	lock  sync.Mutex
+}
+
+func (c *lossyConnection) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.drops > 0 {
+		c.drops--
+
+		return len(b), nil
+	}
+
+	return c.Conn.Write(b)
+}
+
+// newDatagramPipe returns two connected UDP sockets, whose writes don't block until the peer reads as those of net.Pipe do
+func newDatagramPipe(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, err := net.DialUDP("udp", nil, listener.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The local socket is connected as well, so that it only exchanges datagrams with the remote one
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := net.DialUDP("udp", listener.LocalAddr().(*net.UDPAddr), remote.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return local, remote
+}
+
+func TestDatagramStreamRetransmitsControlMessages(t *testing.T) {
+	local, remote := net.Pipe()
+
+	// The first hello and the first acknowledgement are lost
+	client := newDatagramStream(&lossyConnection{Conn: local, drops: 1}, "", keepaliveInterval, idleTimeout)
+	defer client.Close()
+
+	server := newDatagramStream(&lossyConnection{Conn: remote, drops: 1}, "", keepaliveInterval, idleTimeout)
+	defer server.Close()
+
+	// Peers read concurrently, so that retransmissions are acknowledged while the sender waits
+	frames := make(chan *proto.FrameMessage, 2)
+	errs := make(chan error, 1)
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			frame, err := server.Recv()
+			if err != nil {
+				errs <- err
+
+				return
+			}
+
+			frames <- frame
+		}
+	}()
+
+	if err := client.Send(&proto.FrameMessage{Hello: &proto.HelloMessage{PreSharedKey: "pre-shared-key"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Send(&proto.FrameMessage{Content: []byte{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := []*proto.FrameMessage{}
+	for len(received) < 2 {
+		select {
+		case frame := <-frames:
+			received = append(received, frame)
+		case err := <-errs:
+			t.Fatal(err)
+		}
+	}
+
+	if received[0].Hello == nil || received[0].Hello.PreSharedKey != "pre-shared-key" {
+		t.Fatalf("expected the hello, got %v", received[0])
+	}
+
+	// Retransmissions of the hello aren't received again
+	if received[1].Hello != nil || len(received[1].Content) != 3 {
+		t.Fatalf("expected the frame after the hello, got %v", received[1])
+	}
+}
+
+func TestDatagramStreamKeepsIdleStreamsOpen(t *testing.T) {
+	local, remote := net.Pipe()
+
+	client := newDatagramStream(local, "", time.Millisecond*10, time.Millisecond*50)
+	defer client.Close()
+
+	// Accepted streams send keepalives as well, as the hub may have nothing to send to a spoke
+	server := newDatagramStream(remote, "", time.Millisecond*10, time.Millisecond*50)
+	defer server.Close()
+
+	errs := make(chan error, 2)
+	for _, stream := range []*datagramStream{client, server} {
+		go func(stream *datagramStream) {
+			_, err := stream.Recv()
+
+			errs <- err
+		}(stream)
+	}
+
+	select {
+	case err := <-errs:
+		t.Fatalf("expected the idle streams to be kept open, got %v", err)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestDatagramStreamReceivesAcknowledgementsWhileNobodyReads(t *testing.T) {
+	local, remote := newDatagramPipe(t)
+
+	// The acknowledgement of the welcome is lost
+	client := newDatagramStream(&lossyConnection{Conn: local, drops: 1}, "", keepaliveInterval, idleTimeout)
+	defer client.Close()
+
+	server := newDatagramStream(remote, "", keepaliveInterval, idleTimeout)
+	defer server.Close()
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- server.Send(&proto.FrameMessage{Welcome: &proto.WelcomeMessage{}})
+	}()
+
+	welcome, err := client.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if welcome.Welcome == nil {
+		t.Fatalf("expected a welcome, got %v", welcome)
+	}
+
+	// Nobody reads from the server before it has sent the welcome, so the frames which the client sends in the meantime must not block the retransmitted acknowledgements
+	for i := 0; i < receiveBufferSize*2; i++ {
+		if err := client.Send(&proto.FrameMessage{Content: []byte{1, 2, 3}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+}
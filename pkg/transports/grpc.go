@@ -0,0 +1,266 @@
+package transports
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/gloeth/pkg/dialers"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcCloseTimeout = 5 * time.Second
+)
+
+type GRPCTransport struct {
+	address     string
+	tlsConfig   *tls.Config
+	proxyDialer *dialers.ProxyDialer
+}
+
+func NewGRPCTransport(address string, tlsConfig *tls.Config, proxyDialer *dialers.ProxyDialer) *GRPCTransport {
+	return &GRPCTransport{address, tlsConfig, proxyDialer}
+}
+
+func (t *GRPCTransport) Dial() (Stream, error) {
+	stream := &grpcClientStream{}
+
+	// gRPC doesn't expose the connection state to streaming clients, so capture it during the handshake instead
+	tlsConfig := t.tlsConfig.Clone()
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		stream.identityLock.Lock()
+		defer stream.identityLock.Unlock()
+
+		stream.identity = identityFromCertificates(state.PeerCertificates)
+
+		return nil
+	}
+
+	options := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	if t.proxyDialer != nil {
+		options = append(options, grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return t.proxyDialer.DialContext(ctx, "tcp", address)
+		}))
+	}
+
+	connection, err := grpc.Dial(t.address, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := proto.NewFrameServiceClient(connection)
+
+	channel, err := client.TransceiveFrames(context.Background())
+	if err != nil {
+		_ = connection.Close()
+
+		return nil, err
+	}
+
+	stream.FrameService_TransceiveFramesClient = channel
+	stream.connection = connection
+
+	return stream, nil
+}
+
+func (t *GRPCTransport) Listen() (Listener, error) {
+	listenAddress, err := net.ResolveTCPAddr("tcp", t.address)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenTCP("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(t.tlsConfig)))
+
+	grpcListener := &grpcListener{
+		server:  server,
+		streams: make(chan Stream),
+		errs:    make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+
+	reflection.Register(server)
+	proto.RegisterFrameServiceServer(server, grpcListener)
+
+	go func() {
+		grpcListener.errs <- server.Serve(listener)
+	}()
+
+	return grpcListener, nil
+}
+
+func (t *GRPCTransport) MaximumBatchLength() int {
+	return streamBatchLength
+}
+
+type grpcClientStream struct {
+	proto.FrameService_TransceiveFramesClient
+	connection   *grpc.ClientConn
+	identity     string
+	identityLock sync.Mutex
+}
+
+// Recv returns ErrUnauthenticated and ErrPermissionDenied if the server rejected the stream
+func (s *grpcClientStream) Recv() (*proto.FrameMessage, error) {
+	frame, err := s.FrameService_TransceiveFramesClient.Recv()
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated:
+			return nil, remoteError(ErrUnauthenticated, status.Convert(err).Message())
+		case codes.PermissionDenied:
+			return nil, remoteError(ErrPermissionDenied, status.Convert(err).Message())
+		}
+
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func (s *grpcClientStream) Identity() string {
+	s.identityLock.Lock()
+	defer s.identityLock.Unlock()
+
+	return s.identity
+}
+
+// Close half-closes the stream and waits for the server to finish it, so that frames which are still in flight aren't discarded
+func (s *grpcClientStream) Close() error {
+	if err := s.CloseSend(); err == nil {
+		timer := time.NewTimer(grpcCloseTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-s.Context().Done():
+		case <-timer.C:
+		}
+	}
+
+	return s.connection.Close()
+}
+
+// CloseWithError closes the stream normally, as clients can't tell servers why they close their streams
+func (s *grpcClientStream) CloseWithError(err error) error {
+	return s.Close()
+}
+
+type grpcListener struct {
+	proto.UnimplementedFrameServiceServer
+	server    *grpc.Server
+	streams   chan Stream
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *grpcListener) TransceiveFrames(channel proto.FrameService_TransceiveFramesServer) error {
+	stream := &grpcServerStream{channel, make(chan struct{}), nil, sync.Once{}}
+
+	select {
+	case l.streams <- stream:
+	case <-l.done:
+		return io.EOF
+	}
+
+	select {
+	case <-stream.done:
+	case <-channel.Context().Done():
+		return nil
+	}
+
+	// Streams which were closed normally end with an OK status
+	switch {
+	case errors.Is(stream.err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, stream.err.Error())
+	case errors.Is(stream.err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, stream.err.Error())
+	default:
+		return nil
+	}
+}
+
+func (l *grpcListener) Accept() (Stream, error) {
+	select {
+	case stream := <-l.streams:
+		return stream, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, io.EOF
+	}
+}
+
+func (l *grpcListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+
+		// Lets streams which are still open finish with an OK status before they are cancelled
+		stopped := make(chan struct{})
+		go func() {
+			l.server.GracefulStop()
+
+			close(stopped)
+		}()
+
+		timer := time.NewTimer(grpcCloseTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-stopped:
+		case <-timer.C:
+			l.server.Stop()
+		}
+	})
+
+	return nil
+}
+
+type grpcServerStream struct {
+	proto.FrameService_TransceiveFramesServer
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+func (s *grpcServerStream) Identity() string {
+	remote, ok := peer.FromContext(s.Context())
+	if !ok {
+		return ""
+	}
+
+	tlsInfo, ok := remote.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+
+	return identityFromCertificates(tlsInfo.State.PeerCertificates)
+}
+
+func (s *grpcServerStream) Close() error {
+	return s.CloseWithError(nil)
+}
+
+func (s *grpcServerStream) CloseWithError(err error) error {
+	s.closeOnce.Do(func() {
+		s.err = err
+
+		close(s.done)
+	})
+
+	return nil
+}
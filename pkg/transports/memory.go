@@ -0,0 +1,125 @@
+package transports
+
+import (
+	"io"
+	"sync"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// MemoryTransport connects streams within the same process, i.e. to test clients and servers without a network
+type MemoryTransport struct {
+	streams   chan Stream
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{make(chan Stream), make(chan struct{}), &sync.Once{}}
+}
+
+// Dial blocks until the stream has been accepted; it returns io.EOF once the listener has been closed
+func (t *MemoryTransport) Dial() (Stream, error) {
+	local, remote := newMemoryStreamPair()
+
+	select {
+	case t.streams <- remote:
+		return local, nil
+	case <-t.done:
+		return nil, io.EOF
+	}
+}
+
+func (t *MemoryTransport) Listen() (Listener, error) {
+	return &memoryListener{t.streams, t.done, t.closeOnce}, nil
+}
+
+func (t *MemoryTransport) MaximumBatchLength() int {
+	return streamBatchLength
+}
+
+type memoryListener struct {
+	streams   chan Stream
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+func (l *memoryListener) Accept() (Stream, error) {
+	select {
+	case stream := <-l.streams:
+		return stream, nil
+	case <-l.done:
+		return nil, io.EOF
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+
+	return nil
+}
+
+// memoryConnection is shared by both ends of a stream
+type memoryConnection struct {
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+type memoryStream struct {
+	in         chan *proto.FrameMessage
+	out        chan *proto.FrameMessage
+	connection *memoryConnection
+}
+
+func newMemoryStreamPair() (*memoryStream, *memoryStream) {
+	forward := make(chan *proto.FrameMessage)
+	backward := make(chan *proto.FrameMessage)
+	connection := &memoryConnection{done: make(chan struct{})}
+
+	return &memoryStream{backward, forward, connection}, &memoryStream{forward, backward, connection}
+}
+
+// Send copies the frame, as the network transports marshal it before returning and callers may reuse it afterwards
+func (s *memoryStream) Send(frame *proto.FrameMessage) error {
+	select {
+	case s.out <- protobuf.Clone(frame).(*proto.FrameMessage):
+		return nil
+	case <-s.connection.done:
+		return io.EOF
+	}
+}
+
+func (s *memoryStream) Recv() (*proto.FrameMessage, error) {
+	select {
+	case frame := <-s.in:
+		return frame, nil
+	case <-s.connection.done:
+		if s.connection.err != nil {
+			return nil, s.connection.err
+		}
+
+		return nil, io.EOF
+	}
+}
+
+func (s *memoryStream) Identity() string {
+	return ""
+}
+
+func (s *memoryStream) Close() error {
+	return s.CloseWithError(nil)
+}
+
+func (s *memoryStream) CloseWithError(err error) error {
+	s.connection.closeOnce.Do(func() {
+		s.connection.err = err
+
+		close(s.connection.done)
+	})
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package transports
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+// newMemoryStreams returns both ends of a stream of a listening memory transport
+func newMemoryStreams(t *testing.T, transport *MemoryTransport, listener Listener) (Stream, Stream) {
+	t.Helper()
+
+	dialed := make(chan Stream)
+	go func() {
+		stream, err := transport.Dial()
+		if err != nil {
+			t.Error(err)
+		}
+
+		dialed <- stream
+	}()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return <-dialed, server
+}
+
+func TestMemoryTransportRoundTrip(t *testing.T) {
+	transport := NewMemoryTransport()
+
+	listener, err := transport.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, server := newMemoryStreams(t, transport, listener)
+
+	for _, test := range []struct {
+		name     string
+		sender   Stream
+		receiver Stream
+	}{
+		{"client to server", client, server},
+		{"server to client", server, client},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			frame := &proto.FrameMessage{Content: []byte{1, 2, 3}, Sequence: 1}
+
+			go func() {
+				if err := test.sender.Send(frame); err != nil {
+					t.Error(err)
+				}
+
+				// Senders may reuse frames once they have been sent
+				frame.Content[0] = 0xff
+			}()
+
+			received, err := test.receiver.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(received.Content, []byte{1, 2, 3}) || received.Sequence != 1 {
+				t.Fatalf("expected frame with content 010203 and sequence 1, got %x and %v", received.Content, received.Sequence)
+			}
+		})
+	}
+}
+
+func TestMemoryTransportClose(t *testing.T) {
+	transport := NewMemoryTransport()
+
+	listener, err := transport.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := newMemoryStreams(t, transport, listener)
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.Recv(); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	if err := server.Send(&proto.FrameMessage{}); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listener.Accept(); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	if _, err := transport.Dial(); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+}
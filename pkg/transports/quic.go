@@ -0,0 +1,261 @@
+package transports
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	"github.com/quic-go/quic-go"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const (
+	quicProtocol = "gloeth"
+
+	quicErrorMessageTooLong   = 1
+	quicErrorUnauthenticated  = 2
+	quicErrorPermissionDenied = 3
+)
+
+type QUICTransport struct {
+	address   string
+	tlsConfig *tls.Config
+}
+
+func NewQUICTransport(address string, tlsConfig *tls.Config) *QUICTransport {
+	return &QUICTransport{address, tlsConfig}
+}
+
+func (t *QUICTransport) Dial() (Stream, error) {
+	connection, err := quic.DialAddr(context.Background(), t.address, t.config(), newQUICConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return newQUICStream(connection), nil
+}
+
+func (t *QUICTransport) Listen() (Listener, error) {
+	listener, err := quic.ListenAddr(t.address, t.config(), newQUICConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &quicListener{listener}, nil
+}
+
+func (t *QUICTransport) MaximumBatchLength() int {
+	return datagramBatchLength
+}
+
+func (t *QUICTransport) config() *tls.Config {
+	config := t.tlsConfig.Clone()
+	config.NextProtos = []string{quicProtocol}
+
+	return config
+}
+
+func newQUICConfig() *quic.Config {
+	return &quic.Config{
+		EnableDatagrams: true,
+		KeepAlivePeriod: keepaliveInterval,
+		MaxIdleTimeout:  idleTimeout,
+	}
+}
+
+type quicListener struct {
+	listener *quic.Listener
+}
+
+func (l *quicListener) Accept() (Stream, error) {
+	connection, err := l.listener.Accept(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return newQUICStream(connection), nil
+}
+
+func (l *quicListener) Close() error {
+	return l.listener.Close()
+}
+
+// quicStream sends frames as unreliable datagrams, falling back to a unidirectional
+// stream for control messages and frames which don't fit into a single datagram
+type quicStream struct {
+	connection     *quic.Conn
+	frames         chan *proto.FrameMessage
+	errs           chan error
+	fallback       *quic.SendStream
+	fallbackBuffer []byte
+	sendBuffer     []byte
+	sendLock       sync.Mutex
+}
+
+func newQUICStream(connection *quic.Conn) *quicStream {
+	stream := &quicStream{
+		connection: connection,
+		frames:     make(chan *proto.FrameMessage),
+		errs:       make(chan error, 2),
+	}
+
+	go stream.receiveDatagrams()
+	go stream.receiveStreams()
+
+	return stream
+}
+
+func (s *quicStream) Send(frame *proto.FrameMessage) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	// Datagrams are copied by SendDatagram, so the buffer can be reused
+	rawFrame, err := protobuf.MarshalOptions{}.MarshalAppend(s.sendBuffer[:0], frame)
+	if err != nil {
+		return err
+	}
+	s.sendBuffer = rawFrame
+
+	// Control messages must not be lost, so they are only sent on the stream
+	if isControl(frame) {
+		return s.sendFallback(rawFrame)
+	}
+
+	err = s.connection.SendDatagram(rawFrame)
+
+	var tooLarge *quic.DatagramTooLargeError
+	if errors.As(err, &tooLarge) {
+		return s.sendFallback(rawFrame)
+	}
+
+	return err
+}
+
+func (s *quicStream) Recv() (*proto.FrameMessage, error) {
+	select {
+	case frame := <-s.frames:
+		return frame, nil
+	case err := <-s.errs:
+		var applicationError *quic.ApplicationError
+		if errors.As(err, &applicationError) && applicationError.Remote {
+			switch applicationError.ErrorCode {
+			case quicErrorUnauthenticated:
+				return nil, remoteError(ErrUnauthenticated, applicationError.ErrorMessage)
+			case quicErrorPermissionDenied:
+				return nil, remoteError(ErrPermissionDenied, applicationError.ErrorMessage)
+			}
+		}
+
+		return nil, err
+	}
+}
+
+func (s *quicStream) Identity() string {
+	return identityFromCertificates(s.connection.ConnectionState().TLS.PeerCertificates)
+}
+
+func (s *quicStream) Close() error {
+	return s.connection.CloseWithError(0, "")
+}
+
+func (s *quicStream) CloseWithError(err error) error {
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return s.connection.CloseWithError(quicErrorUnauthenticated, err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		return s.connection.CloseWithError(quicErrorPermissionDenied, err.Error())
+	default:
+		return s.Close()
+	}
+}
+
+// sendFallback must be called with the send lock held
+func (s *quicStream) sendFallback(rawFrame []byte) error {
+	if s.fallback == nil {
+		fallback, err := s.connection.OpenUniStream()
+		if err != nil {
+			return err
+		}
+
+		s.fallback = fallback
+	}
+
+	s.fallbackBuffer = binary.BigEndian.AppendUint32(s.fallbackBuffer[:0], uint32(len(rawFrame)))
+	s.fallbackBuffer = append(s.fallbackBuffer, rawFrame...)
+
+	_, err := s.fallback.Write(s.fallbackBuffer)
+
+	return err
+}
+
+func (s *quicStream) receiveDatagrams() {
+	for {
+		rawFrame, err := s.connection.ReceiveDatagram(context.Background())
+		if err != nil {
+			s.errs <- err
+
+			return
+		}
+
+		if !s.receive(rawFrame) {
+			return
+		}
+	}
+}
+
+func (s *quicStream) receiveStreams() {
+	for {
+		stream, err := s.connection.AcceptUniStream(context.Background())
+		if err != nil {
+			s.errs <- err
+
+			return
+		}
+
+		go func() {
+			reader := bufio.NewReader(stream)
+
+			for {
+				var length uint32
+				if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+					return
+				}
+
+				// The length is read before the peer has been authenticated
+				if length > maximumMessageLength {
+					_ = s.connection.CloseWithError(quicErrorMessageTooLong, "message too long")
+
+					return
+				}
+
+				rawFrame := make([]byte, length)
+				if _, err := io.ReadFull(reader, rawFrame); err != nil {
+					return
+				}
+
+				if !s.receive(rawFrame) {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (s *quicStream) receive(rawFrame []byte) bool {
+	frame := &proto.FrameMessage{}
+	if err := protobuf.Unmarshal(rawFrame, frame); err != nil {
+		return true
+	}
+
+	select {
+	case s.frames <- frame:
+		return true
+	case <-s.connection.Context().Done():
+		return false
+	}
+}
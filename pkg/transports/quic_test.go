@@ -0,0 +1,56 @@
+package transports
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+func TestQUICTransportRejectsLongMessagesBeforeBufferingThem(t *testing.T) {
+	serverConfig, clientConfig := newTestTLSConfigs(t)
+	address := newTestAddress(t, "udp")
+
+	listener, err := NewQUICTransport(address, serverConfig).Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		if stream, err := listener.Accept(); err == nil {
+			_, _ = stream.Recv()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	connection, err := quic.DialAddr(ctx, address, NewQUICTransport(address, clientConfig).config(), newQUICConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := connection.OpenUniStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Write(binary.BigEndian.AppendUint32(nil, maximumMessageLength+1)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-connection.Context().Done():
+	case <-ctx.Done():
+		t.Fatal("expected the connection to be closed")
+	}
+
+	var applicationError *quic.ApplicationError
+	if !errors.As(context.Cause(connection.Context()), &applicationError) || applicationError.ErrorCode != quicErrorMessageTooLong {
+		t.Fatalf("expected application error %v, got %v", quicErrorMessageTooLong, context.Cause(connection.Context()))
+	}
+}
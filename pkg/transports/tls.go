@@ -0,0 +1,86 @@
+package transports
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+func NewServerTLSConfig(certificate string, key string, clientCertificateAuthority string) (*tls.Config, error) {
+	keyPair, err := tls.LoadX509KeyPair(certificate, key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{keyPair}}
+
+	if clientCertificateAuthority != "" {
+		certificatePool, err := loadCertificatePool(clientCertificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = certificatePool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+func NewClientTLSConfig(certificateAuthority string, certificate string, key string) (*tls.Config, error) {
+	certificatePool, err := loadCertificatePool(certificateAuthority)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{RootCAs: certificatePool}
+
+	if certificate != "" {
+		keyPair, err := tls.LoadX509KeyPair(certificate, key)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{keyPair}
+	}
+
+	return config, nil
+}
+
+func loadCertificatePool(certificateAuthority string) (*x509.CertPool, error) {
+	rawCertificate, err := os.ReadFile(certificateAuthority)
+	if err != nil {
+		return nil, err
+	}
+
+	certificatePool := x509.NewCertPool()
+	if ok := certificatePool.AppendCertsFromPEM(rawCertificate); !ok {
+		return nil, errors.New("could not parse certificate")
+	}
+
+	return certificatePool, nil
+}
+
+// identityFromCertificates derives the peer's identity from the SAN of its leaf certificate,
+// falling back to the subject's common name
+func identityFromCertificates(certificates []*x509.Certificate) string {
+	if len(certificates) == 0 {
+		return ""
+	}
+
+	certificate := certificates[0]
+
+	switch {
+	case len(certificate.URIs) > 0:
+		return certificate.URIs[0].String()
+	case len(certificate.DNSNames) > 0:
+		return certificate.DNSNames[0]
+	case len(certificate.EmailAddresses) > 0:
+		return certificate.EmailAddresses[0]
+	case len(certificate.IPAddresses) > 0:
+		return certificate.IPAddresses[0].String()
+	default:
+		return certificate.Subject.CommonName
+	}
+}
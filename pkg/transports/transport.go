@@ -0,0 +1,71 @@
+package transports
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+const (
+	// Keeps batches within a single TLS record
+	streamBatchLength = 16 * 1024
+
+	// Keeps batches within a single datagram on common paths; larger ones are fragmented or, i.e. by pion/dtls, which reads into 8 KiB buffers, dropped
+	datagramBatchLength = 1200
+
+	// Exceeds the longest message which peers send, a super-frame with all of its fields, so that longer ones can be rejected before they are buffered
+	maximumMessageLength = 128 * 1024
+)
+
+var (
+	// ErrUnauthenticated is returned to peers which couldn't authenticate themselves, i.e. with an invalid pre-shared key
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrPermissionDenied is returned to peers which authenticated themselves but aren't allowed to connect
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+type Stream interface {
+	Send(frame *proto.FrameMessage) error
+	Recv() (*proto.FrameMessage, error)
+	Identity() string
+	Close() error
+	// CloseWithError closes the stream with ErrUnauthenticated or ErrPermissionDenied, which the peer's Recv returns if the transport can tell it why
+	CloseWithError(err error) error
+}
+
+type Listener interface {
+	Accept() (Stream, error)
+	Close() error
+}
+
+// isControl returns whether the frame is or batches a control message, which datagram transports must not lose
+func isControl(frame *proto.FrameMessage) bool {
+	if frame.Hello != nil || frame.Welcome != nil || frame.Peers != nil {
+		return true
+	}
+
+	if frame.Batch != nil {
+		for _, batched := range frame.Batch.Frames {
+			if batched.Peers != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// remoteError wraps err, which the peer closed the stream with, so that its message isn't prefixed twice
+func remoteError(err error, message string) error {
+	return fmt.Errorf("%w: %v", err, strings.TrimPrefix(message, err.Error()+": "))
+}
+
+type Transport interface {
+	Dial() (Stream, error)
+	Listen() (Listener, error)
+	// MaximumBatchLength is the length which marshalled batches shouldn't exceed; frames which are longer on their own are sent unbatched
+	MaximumBatchLength() int
+}
@@ -0,0 +1,98 @@
+package transports
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+func TestTransportsTellPeersWhyStreamsWereRejected(t *testing.T) {
+	serverConfig, clientConfig := newTestTLSConfigs(t)
+
+	for _, test := range []struct {
+		name          string
+		newTransports func() (Transport, Transport)
+	}{
+		{"grpc", func() (Transport, Transport) {
+			address := newTestAddress(t, "tcp")
+
+			return NewGRPCTransport(address, serverConfig, nil), NewGRPCTransport(address, clientConfig, nil)
+		}},
+		{"websocket", func() (Transport, Transport) {
+			address := newTestAddress(t, "tcp")
+
+			return NewWebSocketTransport(address, "/gloeth", serverConfig, nil), NewWebSocketTransport(address, "/gloeth", clientConfig, nil)
+		}},
+		{"quic", func() (Transport, Transport) {
+			address := newTestAddress(t, "udp")
+
+			return NewQUICTransport(address, serverConfig), NewQUICTransport(address, clientConfig)
+		}},
+		{"memory", func() (Transport, Transport) {
+			transport := NewMemoryTransport()
+
+			return transport, transport
+		}},
+	} {
+		for _, rejection := range []error{ErrUnauthenticated, ErrPermissionDenied} {
+			t.Run(test.name+"/"+rejection.Error(), func(t *testing.T) {
+				serverTransport, clientTransport := test.newTransports()
+
+				listener, err := serverTransport.Listen()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer listener.Close()
+
+				clients := make(chan Stream, 1)
+				errs := make(chan error, 1)
+
+				go func() {
+					client, err := clientTransport.Dial()
+					if err != nil {
+						errs <- err
+
+						return
+					}
+
+					// Some transports only accept streams once the client has sent something
+					if err := client.Send(&proto.FrameMessage{Hello: &proto.HelloMessage{}}); err != nil {
+						errs <- err
+
+						return
+					}
+
+					clients <- client
+				}()
+
+				server, err := listener.Accept()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := server.Recv(); err != nil {
+					t.Fatal(err)
+				}
+
+				var client Stream
+				select {
+				case client = <-clients:
+				case err := <-errs:
+					t.Fatal(err)
+				}
+				defer client.Close()
+
+				reason := fmt.Errorf("%w: rejected", rejection)
+				if err := server.CloseWithError(reason); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := client.Recv(); !errors.Is(err, rejection) || err.Error() != reason.Error() {
+					t.Fatalf("expected %v, got %v", reason, err)
+				}
+			})
+		}
+	}
+}
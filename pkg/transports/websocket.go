@@ -0,0 +1,247 @@
+package transports
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pojntfx/gloeth/pkg/dialers"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Close codes 4000-4999 are reserved for applications
+const (
+	webSocketCloseUnauthenticated  = 4401
+	webSocketClosePermissionDenied = 4403
+)
+
+type WebSocketTransport struct {
+	address     string
+	path        string
+	tlsConfig   *tls.Config
+	proxyDialer *dialers.ProxyDialer
+}
+
+func NewWebSocketTransport(address string, path string, tlsConfig *tls.Config, proxyDialer *dialers.ProxyDialer) *WebSocketTransport {
+	return &WebSocketTransport{address, path, tlsConfig, proxyDialer}
+}
+
+func (t *WebSocketTransport) Dial() (Stream, error) {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  t.tlsConfig,
+		HandshakeTimeout: idleTimeout,
+	}
+
+	// An explicitly configured proxy takes precedence over the environment
+	if t.proxyDialer != nil {
+		dialer.Proxy = nil
+		dialer.NetDialContext = t.proxyDialer.DialContext
+	}
+
+	connection, _, err := dialer.Dial((&url.URL{Scheme: "wss", Host: t.address, Path: t.path}).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWebSocketStream(connection), nil
+}
+
+func (t *WebSocketTransport) Listen() (Listener, error) {
+	listener, err := tls.Listen("tcp", t.address, t.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	webSocketListener := &webSocketListener{
+		streams: make(chan Stream),
+		errs:    make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+
+	upgrader := &websocket.Upgrader{HandshakeTimeout: idleTimeout}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(t.path, func(rw http.ResponseWriter, r *http.Request) {
+		connection, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+
+		stream := newWebSocketStream(connection)
+
+		select {
+		case webSocketListener.streams <- stream:
+		case <-webSocketListener.done:
+			_ = stream.Close()
+		}
+	})
+
+	webSocketListener.server = &http.Server{Handler: mux}
+
+	go func() {
+		webSocketListener.errs <- webSocketListener.server.Serve(listener)
+	}()
+
+	return webSocketListener, nil
+}
+
+func (t *WebSocketTransport) MaximumBatchLength() int {
+	return streamBatchLength
+}
+
+type webSocketListener struct {
+	server    *http.Server
+	streams   chan Stream
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *webSocketListener) Accept() (Stream, error) {
+	select {
+	case stream := <-l.streams:
+		return stream, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, io.EOF
+	}
+}
+
+func (l *webSocketListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+
+		err = l.server.Close()
+	})
+
+	return err
+}
+
+type webSocketStream struct {
+	connection *websocket.Conn
+	sendBuffer []byte
+	sendLock   sync.Mutex
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+func newWebSocketStream(connection *websocket.Conn) *webSocketStream {
+	stream := &webSocketStream{connection, nil, sync.Mutex{}, make(chan struct{}), sync.Once{}}
+
+	// Messages are buffered completely before they are returned, even before the peer has been authenticated
+	connection.SetReadLimit(maximumMessageLength)
+
+	connection.SetPongHandler(func(string) error {
+		return connection.SetReadDeadline(time.Now().Add(idleTimeout))
+	})
+
+	go stream.keepalive()
+
+	return stream
+}
+
+func (s *webSocketStream) Send(frame *proto.FrameMessage) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	rawFrame, err := protobuf.MarshalOptions{}.MarshalAppend(s.sendBuffer[:0], frame)
+	if err != nil {
+		return err
+	}
+	s.sendBuffer = rawFrame
+
+	return s.connection.WriteMessage(websocket.BinaryMessage, rawFrame)
+}
+
+func (s *webSocketStream) Recv() (*proto.FrameMessage, error) {
+	for {
+		if err := s.connection.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			return nil, err
+		}
+
+		messageType, rawFrame, err := s.connection.ReadMessage()
+		if err != nil {
+			var closeError *websocket.CloseError
+			if errors.As(err, &closeError) {
+				switch closeError.Code {
+				case webSocketCloseUnauthenticated:
+					return nil, remoteError(ErrUnauthenticated, closeError.Text)
+				case webSocketClosePermissionDenied:
+					return nil, remoteError(ErrPermissionDenied, closeError.Text)
+				}
+			}
+
+			return nil, err
+		}
+
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		frame := &proto.FrameMessage{}
+		if err := protobuf.Unmarshal(rawFrame, frame); err != nil {
+			return nil, err
+		}
+
+		return frame, nil
+	}
+}
+
+func (s *webSocketStream) Identity() string {
+	connection, ok := s.connection.UnderlyingConn().(*tls.Conn)
+	if !ok {
+		return ""
+	}
+
+	return identityFromCertificates(connection.ConnectionState().PeerCertificates)
+}
+
+func (s *webSocketStream) Close() error {
+	return s.CloseWithError(nil)
+}
+
+func (s *webSocketStream) CloseWithError(reason error) error {
+	code, text := websocket.CloseNormalClosure, ""
+	switch {
+	case errors.Is(reason, ErrUnauthenticated):
+		code, text = webSocketCloseUnauthenticated, reason.Error()
+	case errors.Is(reason, ErrPermissionDenied):
+		code, text = webSocketClosePermissionDenied, reason.Error()
+	}
+
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		_ = s.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+
+		err = s.connection.Close()
+	})
+
+	return err
+}
+
+func (s *webSocketStream) keepalive() {
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepaliveInterval)); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
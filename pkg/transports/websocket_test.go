@@ -0,0 +1,48 @@
+package transports
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	proto "github.com/pojntfx/gloeth/pkg/proto/generated"
+)
+
+func TestWebSocketTransportRejectsLongMessages(t *testing.T) {
+	serverConfig, clientConfig := newTestTLSConfigs(t)
+	address := newTestAddress(t, "tcp")
+
+	listener, err := NewWebSocketTransport(address, "/gloeth", serverConfig, nil).Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := NewWebSocketTransport(address, "/gloeth", clientConfig, nil).Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if err := client.Send(&proto.FrameMessage{Content: make([]byte, 1500)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Send(&proto.FrameMessage{Content: make([]byte, maximumMessageLength)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.Recv(); !errors.Is(err, websocket.ErrReadLimit) {
+		t.Fatalf("expected %v, got %v", websocket.ErrReadLimit, err)
+	}
+}
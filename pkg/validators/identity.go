@@ -0,0 +1,25 @@
+package validators
+
+type IdentityValidator struct {
+	allowedIdentities map[string]struct{}
+}
+
+func NewIdentityValidator(allowedIdentities []string) *IdentityValidator {
+	identities := make(map[string]struct{})
+	for _, identity := range allowedIdentities {
+		identities[identity] = struct{}{}
+	}
+
+	return &IdentityValidator{identities}
+}
+
+// Validate allows every identity if no allowed identities have been configured
+func (v *IdentityValidator) Validate(identity string) bool {
+	if len(v.allowedIdentities) == 0 {
+		return true
+	}
+
+	_, ok := v.allowedIdentities[identity]
+
+	return ok
+}
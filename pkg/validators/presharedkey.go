@@ -1,13 +1,61 @@
 package validators
 
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
 type PreSharedKeyValidator struct {
-	preSharedKey string
+	preSharedKeyHashes [][]byte
 }
 
-func NewPreSharedKeyValidator(preSharedKey string) *PreSharedKeyValidator {
-	return &PreSharedKeyValidator{preSharedKey}
+func NewPreSharedKeyValidator(preSharedKeyHashes [][]byte) *PreSharedKeyValidator {
+	return &PreSharedKeyValidator{preSharedKeyHashes}
 }
 
+// Validate checks the key against every accepted hash so that keys can be rotated; it never
+// returns early so that the time taken doesn't reveal which of the hashes matched
 func (v *PreSharedKeyValidator) Validate(preSharedKey string) bool {
-	return preSharedKey == v.preSharedKey
+	valid := false
+	for _, preSharedKeyHash := range v.preSharedKeyHashes {
+		if err := bcrypt.CompareHashAndPassword(preSharedKeyHash, []byte(preSharedKey)); err == nil {
+			valid = true
+		}
+	}
+
+	return valid
+}
+
+func HashPreSharedKey(preSharedKey string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(preSharedKey), bcrypt.DefaultCost)
+}
+
+// ReadPreSharedKeyHashes reads one hash per line, ignoring empty lines and comments
+func ReadPreSharedKeyHashes(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	preSharedKeyHashes := [][]byte{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		preSharedKeyHashes = append(preSharedKeyHashes, []byte(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return preSharedKeyHashes, nil
 }
@@ -0,0 +1,53 @@
+package validators
+
+const (
+	replayWindowSize  = 2048
+	replayWindowWords = replayWindowSize / 64
+)
+
+// ReplayValidator implements a sliding anti-replay window as described in RFC 6479; it is not safe for concurrent use
+type ReplayValidator struct {
+	highest uint64
+	bitmap  [replayWindowWords]uint64
+}
+
+func NewReplayValidator() *ReplayValidator {
+	return &ReplayValidator{}
+}
+
+// Validate accepts each sequence number once, as long as it isn't older than the window; 0 is never valid
+func (v *ReplayValidator) Validate(sequence uint64) bool {
+	if sequence == 0 {
+		return false
+	}
+
+	index := sequence / 64
+
+	if sequence > v.highest {
+		current := v.highest / 64
+
+		difference := index - current
+		if difference > replayWindowWords {
+			difference = replayWindowWords
+		}
+
+		for i := uint64(1); i <= difference; i++ {
+			v.bitmap[(current+i)%replayWindowWords] = 0
+		}
+
+		v.highest = sequence
+	} else if v.highest-sequence >= replayWindowSize-64 {
+		return false
+	}
+
+	word := &v.bitmap[index%replayWindowWords]
+	bit := uint64(1) << (sequence % 64)
+
+	if *word&bit != 0 {
+		return false
+	}
+
+	*word |= bit
+
+	return true
+}
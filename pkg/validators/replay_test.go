@@ -0,0 +1,57 @@
+package validators
+
+import (
+	"testing"
+)
+
+type replayStep struct {
+	sequence uint64
+	valid    bool
+}
+
+func TestReplayValidatorValidate(t *testing.T) {
+	// Sequence numbers are only accepted within the window except for its partially cleared lowest word
+	const window = replayWindowSize - 64
+
+	tests := []struct {
+		name  string
+		steps []replayStep
+	}{
+		{
+			"in order",
+			[]replayStep{{1, true}, {2, true}, {3, true}, {4, true}},
+		},
+		{
+			"duplicates",
+			[]replayStep{{1, true}, {1, false}, {2, true}, {1, false}, {2, false}},
+		},
+		{
+			"reordered within the window",
+			[]replayStep{{10, true}, {5, true}, {7, true}, {5, false}, {9, true}, {10, false}, {11, true}},
+		},
+		{
+			"just past the window edge",
+			[]replayStep{{3000, true}, {3000 - window, false}, {3000 - window + 1, true}, {3000 - window + 1, false}},
+		},
+		{
+			"jump larger than the window",
+			[]replayStep{{100, true}, {101, true}, {100 + replayWindowSize, true}, {101 + replayWindowSize, true}, {101, false}, {100 + replayWindowSize - 1, true}},
+		},
+		{
+			"zero",
+			[]replayStep{{0, false}, {1, true}, {0, false}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewReplayValidator()
+
+			for i, step := range test.steps {
+				if valid := validator.Validate(step.sequence); valid != step.valid {
+					t.Fatalf("expected sequence %v at step %v to be valid: %v, got %v", step.sequence, i, step.valid, valid)
+				}
+			}
+		})
+	}
+}